@@ -1,5 +1,11 @@
 package main
 
+import (
+	"time"
+
+	"github.com/ngergs/websrv/v3/server"
+)
+
 // config is the general configuration struct
 type config struct {
 	// Log configures log properties
@@ -9,22 +15,75 @@ type config struct {
 	// MediaTypeMap is a map of file extensions like ".jk" to corresponding media types.
 	MediaTypeMap map[string]string `koanf:"mediatypes"`
 	// FallbackPath is the path that should be used as an alternative on HTTP 404 responses. Set to empty to disable.
+	// Directories need a trailing slash and paths ending in /index.html are not allowed as the file server redirects both, use / instead of /index.html.
 	FallbackPath string `koanf:"fallback"`
+	// FallbackCacheControl is the Cache-Control HTTP-Header for responses served via the fallback path. Set to empty to keep the header unchanged.
+	FallbackCacheControl string `koanf:"fallbackcachecontrol"`
+	// FallbackSkipFiles keeps the HTTP 404 for paths whose last segment has a file extension or is a dotfile instead of serving the fallback.
+	FallbackSkipFiles bool `koanf:"fallbackskipfiles"`
+	// Index is a list of file names like index.html, index.htm that are tried in order for directory requests. Empty keeps the default handling.
+	Index []string `koanf:"index"`
+	// EmptyNoContent responds with HTTP 204 instead of an empty HTTP 200 to GET requests for zero-byte files. Does not apply to the fallback.
+	EmptyNoContent bool `koanf:"emptynocontent"`
+	// BaseHref is the path prefix under which the site is deployed. The <base href> of HTML responses is rewritten to it. Empty or "/" disables the rewrite.
+	BaseHref string `koanf:"basehref"`
+	// BomStrip is a list of media types for which a leading UTF-8 byte order mark is stripped from the response. Empty disables.
+	BomStrip []string `koanf:"bomstrip"`
+	// Charset holds the configuration for the charset detection of text files
+	Charset charsetConfig `koanf:"charset"`
+	// CacheControl holds the configuration for Cache-Control HTTP-Header handling
+	CacheControl cacheControlConfig `koanf:"cachecontrol"`
+	// ETag holds the configuration for the ETag computation
+	ETag eTagConfig `koanf:"etag"`
+	// CanonicalQuery holds the configuration for the query string canonicalization
+	CanonicalQuery canonicalQueryConfig `koanf:"canonicalquery"`
+	// Download holds the configuration for responses that are served as download instead of being rendered inline
+	Download downloadConfig `koanf:"download"`
+	// Favicon holds the configuration for the handling of absent /favicon.ico files
+	Favicon faviconConfig `koanf:"favicon"`
+	// LegalBlocks are rules for request paths that are blocked with HTTP 451 Unavailable For Legal Reasons, the first matching rule applies
+	LegalBlocks []legalBlockConfig `koanf:"legalblocks"`
+	// Hidden holds the configuration for paths that are not served even if present in the filesystem
+	Hidden hiddenConfig `koanf:"hidden"`
+	// AllowedExtensions are the only file extensions like ".js" that are served, others and files without extension are rejected with HTTP 404. Empty allows all.
+	AllowedExtensions []string `koanf:"allowedextensions"`
+	// FixedResponses map exact request paths to inline responses, e.g. for /.well-known/security.txt
+	FixedResponses []fixedResponseConfig `koanf:"fixedresponses"`
+	// Hsts holds the configuration for the Strict-Transport-Security HTTP-Header
+	Hsts hstsConfig `koanf:"hsts"`
+	// LinkHeaders holds rules for Link HTTP-Headers that are added to HTML responses like preload or preconnect hints
+	LinkHeaders []linkHeaderConfig `koanf:"linkheaders"`
+	// EarlyHints sends the Link HTTP-Headers from the LinkHeaders as HTTP 103 Early Hints to HTTP/2 and later clients
+	EarlyHints bool `koanf:"earlyhints"`
 	// Metrics holds the configuration for prometheus metrics
 	Metrics metricsConfig `koanf:"metrics"`
 	// MemoryFs enables the in-memory filesystem
 	MemoryFs bool `koanf:"memoryfs"`
+	// MemoryFsWorkers is the number of files that are read in parallel into the in-memory filesystem, which also caps the number of concurrently opened files
+	MemoryFsWorkers int `koanf:"memoryfsworkers"`
+	// MemoryFsMaxBytes is the maximal total size of the files in the in-memory filesystem, also if loaded from an archive. The startup fails if exceeded. 0 disables the limit
+	MemoryFsMaxBytes int64 `koanf:"memoryfsmaxbytes"`
+	// Symlinks determines how symlinks in the target directory are handled. Valid values are root (only follow symlinks within the target directory), deny and follow
+	Symlinks string `koanf:"symlinks"`
+	// CircuitBreaker holds the configuration for the circuit breaker that stops accessing a failing filesystem
+	CircuitBreaker circuitBreakerConfig `koanf:"circuitbreaker"`
 	// H2C enables the h2c (unencrypted HTTP2) endpoint
 	H2C bool `koanf:"h2c"`
+	// HostAllowlist restricts the accepted Host headers, *.example.com allows all subdomains of example.com. Empty allows all hosts.
+	HostAllowlist []string `koanf:"hostallowlist"`
+	// MaxPathLength is the maximal length of request paths in bytes, longer paths are rejected with HTTP 414. 0 disables the limit
+	MaxPathLength int `koanf:"maxpathlength"`
 	// Health enables the health endpoint
 	Health bool `koanf:"health"`
+	// HealthWarmup lets the health endpoint report HTTP 503 until the filesystem has been loaded and the webserver is listening
+	HealthWarmup bool `koanf:"healthwarmup"`
 	// Port holds the configuration for various TCP ports
 	Port portConfig `koanf:"port"`
 	// Gzip holds the configuration for gzip compression handling
 	Gzip gzipConfig `koanf:"gzip"`
 	// Timeout holds the configuration for various timeouts
 	Timeout timeoutConfig `koanf:"timeout"`
-	// ShutdownDelay is the number of seconds to wait before executing a graceful shutdown
+	// ShutdownDelay is the number of seconds to wait after SIGTERM before executing a graceful shutdown, the server keeps serving meanwhile
 	ShutdownDelay int `koanf:"shutdowndelay"`
 	// AngularCspReplace holds the configuration for angular csp fix
 	AngularCspReplace angularCspReplaceConfig `koanf:"angularcsp"`
@@ -48,6 +107,186 @@ type accessLogConfig struct {
 	Health bool `koanf:"health"`
 	// Metrics enables the metrics endpoint access log
 	Metrics bool `koanf:"metrics"`
+	// Levels maps the response status code classes to log levels
+	Levels accessLogLevelConfig `koanf:"levels"`
+	// CompressionRatio adds the uncompressed and compressed response sizes to the access log of compressed responses
+	CompressionRatio bool `koanf:"compressionratio"`
+	// Protocol adds the negotiated protocol (http/1.1, h2, h2c, h3) to the access log
+	Protocol bool `koanf:"protocol"`
+	// ServedPath adds the filesystem path of the served file after rewrites like the fallback to the access log
+	ServedPath bool `koanf:"servedpath"`
+	// LatencyPhases adds the latency until the response status is known, until the first body byte and in total to the access log
+	LatencyPhases bool `koanf:"latencyphases"`
+	// File writes the access log to a rotating file instead of the application log
+	File accessLogFileConfig `koanf:"file"`
+}
+
+// accessLogFileConfig configures the rotating access log file
+type accessLogFileConfig struct {
+	// Path of the access log file, rotated files are stored in the same directory. Empty disables the access log file.
+	Path string `koanf:"path"`
+	// MaxSize is the size in megabytes after which the file is rotated
+	MaxSize int `koanf:"maxsize"`
+	// MaxAge is the number of days to retain rotated files, 0 retains them regardless of their age
+	MaxAge int `koanf:"maxage"`
+	// MaxBackups is the number of rotated files to retain, 0 retains all of them
+	MaxBackups int `koanf:"maxbackups"`
+	// Compress gzips the rotated files
+	Compress bool `koanf:"compress"`
+	// RotateInterval rotates the file additionally after the given duration like 24h, 0 disables time based rotation
+	RotateInterval time.Duration `koanf:"rotateinterval"`
+}
+
+// accessLogLevelConfig maps the response status code classes to log levels. Valid values are debug, info, warn, error
+type accessLogLevelConfig struct {
+	// Success is the log level for 1xx and 2xx responses
+	Success string `koanf:"success"`
+	// Redirect is the log level for 3xx responses
+	Redirect string `koanf:"redirect"`
+	// ClientError is the log level for 4xx responses
+	ClientError string `koanf:"clienterror"`
+	// ServerError is the log level for 5xx responses
+	ServerError string `koanf:"servererror"`
+}
+
+// cacheControlConfig holds the configuration for Cache-Control HTTP-Header handling
+type cacheControlConfig struct {
+	// QueryParam is a cache-busting query parameter like "v" for app.js?v=123. Responses to requests with it are marked as immutable. Empty disables.
+	QueryParam string `koanf:"queryparam"`
+	// HashedPath holds the configuration for cache-busting via a hash segment in the path like /assets/<hash>/app.js
+	HashedPath hashedPathConfig `koanf:"hashedpath"`
+	// Rules set the Cache-Control HTTP-Header per path, the first matching rule applies
+	Rules []cacheControlRuleConfig `koanf:"rules"`
+}
+
+// cacheControlRuleConfig sets the Cache-Control HTTP-Header for matching paths
+type cacheControlRuleConfig struct {
+	// Path is a regular expression for the request paths
+	Path string `koanf:"path"`
+	// Value is passed through verbatim, e.g. "public, max-age=60, stale-while-revalidate=600, stale-if-error=86400"
+	Value string `koanf:"value"`
+}
+
+// hashedPathConfig holds the configuration for cache-busting via a hash segment in the path like /assets/<hash>/app.js.
+// The hash segment is removed for the file lookup and successful responses are marked as immutable.
+type hashedPathConfig struct {
+	// Segment is the zero-based index of the path segment that holds the hash, e.g. 1 for /assets/<hash>/app.js
+	Segment int `koanf:"segment"`
+	// Pattern is a regular expression the hash segment has to match, like "^[0-9a-f]{8,}$". Empty disables.
+	Pattern string `koanf:"pattern"`
+}
+
+// eTagConfig holds the configuration for the ETag computation
+type eTagConfig struct {
+	// Algorithm is the hash algorithm for the ETag computation. Valid values are sha256, xxhash and crc32
+	Algorithm string `koanf:"algorithm"`
+	// MaxEntries is the maximum number of ETags that are stored, the least recently used are evicted. 0 uses the default of 10000
+	MaxEntries int `koanf:"maxentries"`
+	// ContentDigest adds the RFC 9530 Content-Digest HTTP-Header with the SHA-256 hash of the response body as sent, i.e. after compression
+	ContentDigest bool `koanf:"contentdigest"`
+}
+
+// canonicalQueryConfig holds the configuration for the query string canonicalization.
+// The query parameters are sorted by key and duplicate key-value pairs are dropped to improve the hit rate of caching proxies.
+type canonicalQueryConfig struct {
+	// Enabled activates the query string canonicalization
+	Enabled bool `koanf:"enabled"`
+	// Ignore are query parameters that are dropped, a trailing * matches all parameters with the prefix like utm_*
+	Ignore []string `koanf:"ignore"`
+	// Redirect responds with HTTP 301 to the canonical URL, otherwise the request is rewritten internally
+	Redirect bool `koanf:"redirect"`
+}
+
+// downloadConfig holds the media types and paths whose responses receive a Content-Disposition: attachment HTTP-Header
+type downloadConfig struct {
+	// MediaTypes are media types without parameters like application/pdf
+	MediaTypes []string `koanf:"mediatypes"`
+	// Paths are regular expressions for the request paths, like "^/downloads/"
+	Paths []string `koanf:"paths"`
+}
+
+// faviconConfig holds the configuration for the handling of absent /favicon.ico files
+type faviconConfig struct {
+	// Enabled activates the favicon fallback
+	Enabled bool `koanf:"enabled"`
+	// FallbackPath is the path that is served when /favicon.ico is absent. Empty responds with HTTP 204 instead.
+	FallbackPath string `koanf:"fallback"`
+}
+
+// legalBlockConfig blocks the matching paths with HTTP 451 Unavailable For Legal Reasons
+type legalBlockConfig struct {
+	// Path is a regular expression for the request paths
+	Path string `koanf:"path"`
+	// Authority is the URL of the entity that requested the block, it is referenced in the Link HTTP-Header with rel="blocked-by". Empty omits the header.
+	Authority string `koanf:"authority"`
+	// Notice is the response body, empty defaults to the status text
+	Notice string `koanf:"notice"`
+}
+
+// charsetConfig holds the configuration for the charset detection of text files
+type charsetConfig struct {
+	// MediaTypes are the text media types for which the charset is detected. Empty disables.
+	MediaTypes []string `koanf:"mediatypes"`
+	// Transcode converts UTF-16 and windows-1252 (Latin-1) files to UTF-8 instead of only declaring the detected charset
+	Transcode bool `koanf:"transcode"`
+}
+
+// hiddenConfig holds the configuration for paths that are answered with HTTP 404 even if present in the filesystem
+type hiddenConfig struct {
+	// Dotfiles hides all paths with a segment that starts with a dot like /.env or /.git/config
+	Dotfiles bool `koanf:"dotfiles"`
+	// Allow are path prefixes like /.well-known/ below which the dotfile check starts
+	Allow []string `koanf:"allow"`
+	// Patterns are regular expressions for further hidden request paths
+	Patterns []string `koanf:"patterns"`
+}
+
+// fixedResponseConfig holds an inline response for an exact request path
+type fixedResponseConfig struct {
+	// Path is the exact request path, like "/.well-known/security.txt"
+	Path string `koanf:"path"`
+	// Status is the HTTP status code, 0 defaults to 200
+	Status int `koanf:"status"`
+	// ContentType is the Content-Type HTTP-Header, empty defaults to "text/plain; charset=utf-8"
+	ContentType string `koanf:"contenttype"`
+	// Body is the response body
+	Body string `koanf:"body"`
+	// Override serves the response even if a file exists under the path
+	Override bool `koanf:"override"`
+}
+
+// circuitBreakerConfig holds the configuration for the circuit breaker of the filesystem, e.g. for network-backed volumes.
+// While the circuit is open the requests are answered with HTTP 503 without accessing the filesystem.
+type circuitBreakerConfig struct {
+	// Enabled activates the circuit breaker
+	Enabled bool `koanf:"enabled"`
+	// Threshold is the number of consecutive failed file accesses after which the circuit opens, missing files do not count
+	Threshold int `koanf:"threshold"`
+	// Cooldown in seconds after which a single trial access tests whether the filesystem has recovered
+	Cooldown int `koanf:"cooldown"`
+}
+
+// hstsConfig holds the directives of the Strict-Transport-Security HTTP-Header, which is only sent for HTTPS requests
+type hstsConfig struct {
+	// Enabled activates the Strict-Transport-Security HTTP-Header
+	Enabled bool `koanf:"enabled"`
+	// MaxAge in seconds for which browsers only use HTTPS, the preload list requires at least one year (31536000)
+	MaxAge int `koanf:"maxage"`
+	// IncludeSubDomains extends the policy to all subdomains, required by the preload list
+	IncludeSubDomains bool `koanf:"includesubdomains"`
+	// Preload signals consent to be added to the HSTS preload list of the browsers
+	Preload bool `koanf:"preload"`
+	// TrustForwardedProto sends the header for requests with the X-Forwarded-Proto HTTP-Header https from a TLS-terminating reverse proxy.
+	// As the webserver has no TLS listener, the header is never sent without it. Only enable it if the proxy overwrites this header for all requests.
+	TrustForwardedProto bool `koanf:"trustforwardedproto"`
+}
+
+// linkHeaderConfig holds Link HTTP-Header values for the HTML responses of the matching paths
+type linkHeaderConfig struct {
+	// Path is a regular expression for the request paths, like "^/$"
+	Path string `koanf:"path"`
+	// Links are the Link HTTP-Header values, like "</main.js>; rel=preload; as=script"
+	Links []string `koanf:"links"`
 }
 
 // metricsConfig holds the prometheus metrics configuration
@@ -56,6 +295,20 @@ type metricsConfig struct {
 	Enabled bool `koanf:"enabled"`
 	// Namespace is the prometheus namespace
 	Namespace string `koanf:"namespace"`
+	// Protocol adds a metric for the number of requests per negotiated protocol (http/1.1, h2, h2c, h3)
+	Protocol bool `koanf:"protocol"`
+	// HitCounter holds the configuration for the most requested files endpoint
+	HitCounter hitCounterConfig `koanf:"hitcounter"`
+}
+
+// hitCounterConfig holds the configuration for the most requested files endpoint
+type hitCounterConfig struct {
+	// Enabled serves the most requested files since start under /hits on the metrics port
+	Enabled bool `koanf:"enabled"`
+	// MaxEntries is the maximal number of tracked files to bound the memory usage
+	MaxEntries int `koanf:"maxentries"`
+	// Top is the default number of returned files, can be overridden via the query parameter n
+	Top int `koanf:"top"`
 }
 
 // portConfig holds configurations for various TCP ports
@@ -74,7 +327,8 @@ type portConfig struct {
 type gzipConfig struct {
 	// Enabled activates the gzip response compression
 	Enabled bool `koanf:"enabled"`
-	// CompressionLevel is the amount of compression, values are between 1 and 9
+	// CompressionLevel is the amount of compression for responses compressed on the fly, values are between 1 (fastest) and 9 (smallest).
+	// The in-memory filesystem is compressed once at startup and always uses the best compression.
 	CompressionLevel int `koanf:"compression"`
 	// MediaTypes is a slice of media type (according to the response HTTP Content-Type header) that should be compressed
 	MediaTypes []string `koanf:"mediatypes"`
@@ -90,6 +344,8 @@ type timeoutConfig struct {
 	Write int `koanf:"write"`
 	// Shutdown is the graceful shutdown timeout in seconds
 	Shutdown int `koanf:"shutdown"`
+	// DrainLog is the interval in seconds in which the number of remaining in-flight requests is logged during the graceful shutdown. 0 disables the logging.
+	DrainLog int `koanf:"drainlog"`
 }
 
 // angularCspReplaceConfig holds the configuration for the angular csp replace fix
@@ -114,7 +370,23 @@ type cookieConfig struct {
 
 //nolint:mnd
 var defaultConfig = config{
-	Log: logConfig{Level: "info"},
+	HealthWarmup:         false,
+	Symlinks:             "root",
+	MemoryFsWorkers:      4,
+	MaxPathLength:        server.DefaultMaxPathLength,
+	FallbackCacheControl: "no-cache",
+	Log: logConfig{
+		Level: "info",
+		AccessLog: accessLogConfig{
+			Levels: accessLogLevelConfig{
+				Success:     "info",
+				Redirect:    "info",
+				ClientError: "warn",
+				ServerError: "error",
+			},
+			File: accessLogFileConfig{MaxSize: 100},
+		},
+	},
 	Port: portConfig{
 		Webserver: 8080,
 		Health:    8081,
@@ -136,7 +408,12 @@ var defaultConfig = config{
 		".woff2": "font/woff2",
 		".txt":   "text/plain",
 	},
-	Metrics:       metricsConfig{Namespace: "websrv"},
-	Timeout:       timeoutConfig{Idle: 30, Read: 10, Write: 10, Shutdown: 5},
-	ShutdownDelay: 5,
+	CanonicalQuery: canonicalQueryConfig{Redirect: true},
+	ETag:           eTagConfig{Algorithm: "sha256", MaxEntries: 10000},
+	Metrics:        metricsConfig{Namespace: "websrv", HitCounter: hitCounterConfig{MaxEntries: 10000, Top: 20}},
+	Timeout:        timeoutConfig{Idle: 30, Read: 10, Write: 10, Shutdown: 5},
+	ShutdownDelay:  5,
+	Hsts:           hstsConfig{MaxAge: 63072000, IncludeSubDomains: true},
+	CircuitBreaker: circuitBreakerConfig{Threshold: 5, Cooldown: 10},
+	Hidden:         hiddenConfig{Dotfiles: true, Allow: []string{"/.well-known/"}},
 }
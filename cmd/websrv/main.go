@@ -1,9 +1,9 @@
 package main
 
 import (
-	"compress/gzip"
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -12,17 +12,20 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"io/fs"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/ngergs/websrv/v3/filesystem"
 	"github.com/ngergs/websrv/v3/server"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
 	_ "github.com/KimMachineGun/automemlimit"
@@ -35,140 +38,445 @@ func main() {
 	if err != nil {
 		log.Fatal().Err(err).Msg("Error reading configuration: See https://github.com/ngergs/websrv/config.yaml for the expected structure.")
 	}
+	if *validateOnly {
+		os.Exit(runValidation(conf))
+	}
 
 	targetDir, err := setup(conf)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Error during initialization")
 	}
-	if err := landlockFsReadonlyDir(ll, targetDir); err != nil {
+	if err := landlockFs(ll, targetDir, *confFile, conf.Log.AccessLog.File.Path); err != nil {
 		log.Fatal().Err(err).Msg("")
 	}
-	var wg sync.WaitGroup
+	// the access log file is closed last to capture the access logs of the draining servers
+	var shutdownPhases server.ShutdownPhases
+	errChan := make(chan error)
 	sigtermCtx := server.SigTermCtx(context.Background(), time.Duration(conf.ShutdownDelay)*time.Second)
-	unzipfs, zipfs := initFs(targetDir, conf)
 
-	errChan := make(chan error)
-	var promRegistration *server.PrometheusRegistration
+	var resources handlerResources
+	var accessLogFile *server.AccessLogFile
+	if conf.Log.AccessLog.File.Path != "" {
+		accessLogFile = server.NewAccessLogFile(accessLogFileOptions(&conf.Log.AccessLog.File))
+		resources.accessLogger = accessLogFile.Logger()
+		go accessLogFile.RotatePeriodically(sigtermCtx)
+		shutdownPhases.Register(server.ShutdownPhaseLogs, "access log file", server.ShutdownFunc(func(_ context.Context) error {
+			return accessLogFile.Close()
+		}), time.Duration(conf.Timeout.Shutdown)*time.Second)
+	}
+	accessLogOpts, err := accessLogOptions(&conf.Log.AccessLog, resources.accessLogger)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid access log configuration")
+	}
+
+	// the health server is started first so that it reports not ready (HTTP 503) during the warmup
+	var ready atomic.Bool
+	ready.Store(!conf.HealthWarmup)
+	var healthServer *server.Server
+	if conf.Health {
+		healthServer = server.Build(conf.Port.Health, time.Duration(conf.Timeout.Read)*time.Second,
+			time.Duration(conf.Timeout.Write)*time.Second, time.Duration(conf.Timeout.Idle)*time.Second,
+			server.HealthCheckConditionalHandler(ready.Load),
+			server.Optional(server.AccessLogWithOptions(accessLogOpts), conf.Log.AccessLog.Health),
+		)
+		// the metrics are not registered yet, the health server only logs its errors
+		healthServer.ErrorLog = server.NewErrorLog(nil)
+		log.Info().Msgf("Starting healthcheck server on port %d", conf.Port.Health)
+		healthServer.ListenGoServe(errChan)
+		// stop health server after the webserver has stopped, 1 second is sufficient for health checks to shut down
+		shutdownPhases.Register(server.ShutdownPhaseMetrics, "health server", healthServer, time.Duration(1)*time.Second)
+	}
+
+	resources.unzipfs, resources.zipfs = initFs(targetDir, conf)
+	if conf.CircuitBreaker.Enabled {
+		resources.circuitBreaker = filesystem.NewCircuitBreaker(filesystem.CircuitBreakerOptions{
+			Threshold: conf.CircuitBreaker.Threshold,
+			Cooldown:  time.Duration(conf.CircuitBreaker.Cooldown) * time.Second,
+		})
+		// both filesystems share the backend and hence the breaker
+		resources.unzipfs = resources.circuitBreaker.FS(resources.unzipfs)
+		if resources.zipfs != nil {
+			resources.zipfs = resources.circuitBreaker.FS(resources.zipfs)
+		}
+	}
+
+	if conf.Metrics.Enabled && conf.Metrics.HitCounter.Enabled {
+		resources.hitCounter = server.NewHitCounter(conf.Metrics.HitCounter.MaxEntries)
+	}
 	if conf.Metrics.Enabled {
-		promRegistration, err = server.AccessMetricsRegister(prometheus.DefaultRegisterer, conf.Metrics.Namespace)
+		// the TLS handshake failures are not counted as the webserver has no TLS listener
+		resources.promRegistration, err = server.AccessMetricsRegisterWithOptions(prometheus.DefaultRegisterer, conf.Metrics.Namespace,
+			server.AccessMetricsOptions{Protocol: conf.Metrics.Protocol})
 		if err != nil {
 			log.Error().Err(err).Msg("Could not register custom prometheus metrics.")
 		}
+		if resources.circuitBreaker != nil {
+			if err := server.CircuitBreakerMetricsRegister(prometheus.DefaultRegisterer, conf.Metrics.Namespace, resources.circuitBreaker); err != nil {
+				log.Error().Err(err).Msg("Could not register the circuit breaker metrics.")
+			}
+		}
+	}
+
+	isThrottled := *devLatency > 0 || *devBandwidth > 0
+	if isThrottled {
+		log.Warn().Msgf("Development throttling active with latency %v and bandwidth %d bytes/s, do not use in production", *devLatency, *devBandwidth)
+	}
+
+	if conf.Hsts.Enabled {
+		if err := server.CheckHstsPreload(hstsOptions(&conf.Hsts)); err != nil {
+			log.Warn().Err(err).Msg("HSTS preload is active, but the site would be rejected by the preload list")
+		}
+		if !conf.Hsts.TrustForwardedProto {
+			log.Warn().Msg("HSTS is active, but the webserver has no TLS listener. The Strict-Transport-Security HTTP-Header is only sent with hsts.trustforwardedproto behind a TLS-terminating reverse proxy")
+		}
+	}
+
+	if *devPprof {
+		log.Warn().Msg("Development pprof profiles with request labels active, do not use in production")
+		if !conf.Metrics.Enabled {
+			log.Warn().Msg("The pprof profiles are served on the metrics port, but the metrics are disabled")
+		}
+	}
+
+	if *devSuggest {
+		log.Warn().Msg("Development file name suggestions for HTTP 404 responses active, do not use in production")
+	}
+
+	handler, err := buildHandler(conf, &resources)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid configuration")
+	}
+	reloadable := server.NewReloadableHandler(handler)
+	go server.OnSigHup(sigtermCtx, func() {
+		reloadConfig(conf, reloadable, &resources)
+	})
+
+	webserver := server.Build(conf.Port.Webserver, time.Duration(conf.Timeout.Read)*time.Second,
+		time.Duration(conf.Timeout.Write)*time.Second, time.Duration(conf.Timeout.Idle)*time.Second, reloadable)
+	webserver.ErrorLog = server.NewErrorLog(resources.promRegistration)
+	// OPTIONS * is answered by the server.OptionsAsterisk middleware
+	webserver.DisableGeneralOptionsHandler = true
+	log.Info().Msgf("Starting webserver server on port %d", conf.Port.Webserver)
+	srvCtx := context.WithValue(sigtermCtx, server.ServerName, "file server")
+	shutdownPhases.Register(server.ShutdownPhaseServe, "file server", webserver, time.Duration(conf.Timeout.Shutdown)*time.Second)
+	if conf.Timeout.DrainLog != 0 {
+		go server.LogDrainProgress(srvCtx, &resources.inFlight, time.Duration(conf.Timeout.DrainLog)*time.Second, time.Duration(conf.Timeout.Shutdown)*time.Second)
+	}
+	webserver.ListenGoServe(errChan)
+
+	if conf.Metrics.Enabled {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/", promhttp.Handler())
+		if resources.hitCounter != nil {
+			metricsMux.Handle("/hits", server.TopHitsHandler(resources.hitCounter, conf.Metrics.HitCounter.Top))
+		}
+		if *devPprof {
+			metricsMux.HandleFunc("/debug/pprof/", pprof.Index)
+			metricsMux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		}
+		metricsServer := server.Build(conf.Port.Metrics, time.Duration(conf.Timeout.Read)*time.Second,
+			time.Duration(conf.Timeout.Write)*time.Second, time.Duration(conf.Timeout.Idle)*time.Second,
+			metricsMux, server.Optional(server.AccessLogWithOptions(accessLogOpts), conf.Log.AccessLog.Metrics))
+		metricsServer.ErrorLog = server.NewErrorLog(resources.promRegistration)
+		shutdownPhases.Register(server.ShutdownPhaseMetrics, "prometheus metrics server", metricsServer, time.Duration(conf.Timeout.Shutdown)*time.Second)
+		metricsServer.ListenGoServe(errChan)
+		log.Info().Msgf("Listening for prometheus metric scrapes under container port tcp/%s", metricsServer.Addr[1:])
 	}
 
+	go logErrors(errChan)
+
+	if conf.HealthWarmup {
+		log.Info().Msg("Warmup finished, reporting ready")
+		ready.Store(true)
+	}
+	if err := landlockNetwork(ll); err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+
+	if err := shutdownPhases.Run(sigtermCtx); err != nil {
+		log.Warn().Err(err).Msg("Error during graceful shutdown")
+	}
+}
+
+// handlerResources holds the state that is shared between the webserver handlers of the initial and reloaded configurations
+type handlerResources struct {
+	unzipfs          fs.ReadFileFS
+	zipfs            fs.ReadFileFS
+	promRegistration *server.PrometheusRegistration
+	inFlight         server.InFlightCounter
+	hitCounter       *server.HitCounter
+	accessLogger     *zerolog.Logger
+	circuitBreaker   *filesystem.CircuitBreaker
+}
+
+// buildHandler builds the webserver handler from the reloadable parts of the configuration
+func buildHandler(conf *config, resources *handlerResources) (http.Handler, error) {
+	unzipfs, zipfs := resources.unzipfs, resources.zipfs
+	if err := checkConfig(conf); err != nil {
+		return nil, err
+	}
+	accessLogOpts, err := accessLogOptions(&conf.Log.AccessLog, resources.accessLogger)
+	if err != nil {
+		return nil, err
+	}
+	if conf.FallbackPath != "" {
+		if err := server.ValidateFallback(unzipfs, conf.FallbackPath); err != nil {
+			return nil, fmt.Errorf("invalid fallback configuration: %w", err)
+		}
+	}
+	eTagHash, err := server.HashFuncByName(conf.ETag.Algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("invalid etag configuration: %w", err)
+	}
+
+	var hashedPathRegex *regexp.Regexp
+	if conf.CacheControl.HashedPath.Pattern != "" {
+		hashedPathRegex, err = regexp.Compile(conf.CacheControl.HashedPath.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hashed path pattern: %w", err)
+		}
+	}
+
+	linkRules := make([]server.LinkRule, len(conf.LinkHeaders))
+	for i, linkHeader := range conf.LinkHeaders {
+		linkPathRegex, err := regexp.Compile(linkHeader.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid link header path: %w", err)
+		}
+		linkRules[i] = server.LinkRule{Path: linkPathRegex, Links: linkHeader.Links}
+	}
+
+	cacheControlRules := make([]server.CacheControlRule, len(conf.CacheControl.Rules))
+	for i, rule := range conf.CacheControl.Rules {
+		cacheControlPathRegex, err := regexp.Compile(rule.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache control rule path: %w", err)
+		}
+		cacheControlRules[i] = server.CacheControlRule{Path: cacheControlPathRegex, Value: rule.Value}
+	}
+	attachmentPaths := make([]*regexp.Regexp, len(conf.Download.Paths))
+	for i, downloadPath := range conf.Download.Paths {
+		attachmentPaths[i], err = regexp.Compile(downloadPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid download path: %w", err)
+		}
+	}
+	isDownload := len(attachmentPaths) != 0 || len(conf.Download.MediaTypes) != 0
+	legalBlockRules := make([]server.LegalBlockRule, len(conf.LegalBlocks))
+	for i, rule := range conf.LegalBlocks {
+		legalBlockPathRegex, err := regexp.Compile(rule.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid legal block path: %w", err)
+		}
+		legalBlockRules[i] = server.LegalBlockRule{Path: legalBlockPathRegex, Authority: rule.Authority, Notice: rule.Notice}
+	}
+	hiddenOpts := server.HiddenOptions{Dotfiles: conf.Hidden.Dotfiles, Allow: conf.Hidden.Allow, Patterns: make([]*regexp.Regexp, len(conf.Hidden.Patterns))}
+	for i, pattern := range conf.Hidden.Patterns {
+		hiddenOpts.Patterns[i], err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hidden path pattern: %w", err)
+		}
+	}
+
+	isThrottled := *devLatency > 0 || *devBandwidth > 0
 	r := chi.NewRouter()
 	r.Use(
 		server.Optional(server.H2C(conf.Port.H2c), conf.H2C),
+		server.Optional(server.InFlight(&resources.inFlight), conf.Timeout.DrainLog != 0),
 		middleware.RequestID,
+		server.RequestLogger(),
 		middleware.RealIP,
+		// rejected hosts and overlong paths are not logged or recorded in the metrics to prevent unbounded label cardinality and log sizes
+		server.HostAllowlist(conf.HostAllowlist),
+		server.Optional(server.MaxPathLength(conf.MaxPathLength), conf.MaxPathLength > 0),
 		middleware.Timeout(time.Duration(conf.Timeout.Write)*time.Second),
-		server.Optional(server.AccessLog(), conf.Log.AccessLog.General),
-		server.Optional(server.AccessMetrics(promRegistration), conf.Metrics.Enabled),
+		server.Optional(server.AccessLogWithOptions(accessLogOpts), conf.Log.AccessLog.General),
+		server.Optional(server.AccessMetrics(resources.promRegistration), conf.Metrics.Enabled),
+		server.WriteErrors(resources.promRegistration),
+		server.Optional(server.Throttle(*devLatency, *devBandwidth), isThrottled),
+		// before the validation which only allows GET and HEAD requests, so only these are advertised
+		server.OptionsAsterisk(http.MethodGet, http.MethodHead),
 		server.Validate(),
+		server.Optional(server.ProfileLabels(), *devPprof),
+		// outer to the fallback, so that rejected files are not served via the fallback
+		server.Optional(server.Hidden(hiddenOpts), conf.Hidden.Dotfiles || len(hiddenOpts.Patterns) != 0),
+		server.Optional(server.AllowedExtensions(unzipfs, conf.AllowedExtensions), len(conf.AllowedExtensions) != 0),
+		server.Optional(server.LegalBlock(legalBlockRules), len(legalBlockRules) != 0),
+		// outer to all handlers that access the filesystem
+		server.Optional(server.CircuitBreaker(resources.circuitBreaker), resources.circuitBreaker != nil),
+		server.Optional(server.CanonicalQuery(server.CanonicalQueryOptions{Ignore: conf.CanonicalQuery.Ignore, Redirect: conf.CanonicalQuery.Redirect}),
+			conf.CanonicalQuery.Enabled),
+		server.Optional(server.HitCounting(resources.hitCounter), resources.hitCounter != nil),
 		server.Header(conf.Headers),
+		// inner to the static headers, so that the configured directives take precedence over a static Strict-Transport-Security HTTP-Header
+		server.Optional(server.Hsts(hstsOptions(&conf.Hsts)), conf.Hsts.Enabled),
+		server.Optional(server.EarlyHints(linkRules), conf.EarlyHints && len(linkRules) != 0),
+		server.Optional(server.LinkHeader(linkRules), len(linkRules) != 0),
+		server.Optional(server.CacheBustingQuery(conf.CacheControl.QueryParam), conf.CacheControl.QueryParam != ""),
 		server.Optional(server.SessionId(conf.AngularCspReplace.SessionCookie.Name, time.Duration(conf.AngularCspReplace.SessionCookie.MaxAge)*time.Second),
 			conf.AngularCspReplace.Enabled),
 		server.Optional(server.CspHeaderReplace(conf.AngularCspReplace.VariableName), conf.AngularCspReplace.Enabled),
-		server.Optional(server.Fallback(conf.FallbackPath, http.StatusNotFound), conf.FallbackPath != ""),
+		// the suggestions skip the files rejected by the hidden and allowed extensions handlers, so that they are not revealed
+		server.Optional(server.NotFoundSuggestionWithOptions(unzipfs, server.NotFoundSuggestionOptions{
+			Hidden:            hiddenOpts,
+			AllowedExtensions: conf.AllowedExtensions,
+		}), *devSuggest),
+		server.Optional(server.FallbackWithOptions(conf.FallbackPath, server.FallbackOptions{
+			CacheControl: conf.FallbackCacheControl,
+			SkipFiles:    conf.FallbackSkipFiles,
+		}, http.StatusNotFound), conf.FallbackPath != ""),
+		server.Optional(server.Favicon(conf.Favicon.FallbackPath), conf.Favicon.Enabled),
+		server.Optional(server.HashedPath(conf.CacheControl.HashedPath.Segment, hashedPathRegex), hashedPathRegex != nil),
+		// inner to the cache-busting and fallback handlers, so that their Cache-Control HTTP-Headers take precedence
+		server.Optional(server.CacheControlRules(cacheControlRules), len(cacheControlRules) != 0),
+		server.Optional(server.Attachment(server.AttachmentOptions{MediaTypes: conf.Download.MediaTypes, Paths: attachmentPaths}), isDownload),
+		server.Optional(server.FixedResponses(unzipfs, fixedResponses(conf.FixedResponses)), len(conf.FixedResponses) != 0),
+		server.Optional(server.Index(unzipfs, conf.Index), len(conf.Index) != 0),
+		server.Optional(server.EmptyFile(unzipfs), conf.EmptyNoContent),
+		server.NegotiatedError(http.StatusNotFound, http.StatusInternalServerError),
 	)
 
-	unzipHandler := http.FileServer(http.FS(unzipfs))
-	staticZipHandler := server.Caching()(http.FileServer(http.FS(zipfs)))
-	dynamicZipHandler := server.Caching()(middleware.Compress(gzip.DefaultCompression, conf.Gzip.MediaTypes...)(unzipHandler))
+	// the response transformations have to happen prior to compression
+	unzipHandler := server.BaseHref(conf.BaseHref)(
+		server.Optional(server.BomStrip(conf.BomStrip), len(conf.BomStrip) != 0)(
+			// inner to the byte order mark stripping, as the byte order mark determines the charset
+			server.Optional(server.Charset(server.CharsetOptions{MediaTypes: conf.Charset.MediaTypes, Transcode: conf.Charset.Transcode}),
+				len(conf.Charset.MediaTypes) != 0)(
+				server.FileServer(unzipfs))))
+	rewritesHtml := conf.BaseHref != "" && conf.BaseHref != "/"
+	// the pre-zipped files can not be transformed, the fallback is assumed to be an HTML document
+	isTransformed := func(requestPath string, mediaType string) bool {
+		if requestPath == conf.FallbackPath {
+			mediaType = "text/html"
+		}
+		return (rewritesHtml && mediaType == "text/html") || utils.Contains(conf.BomStrip, mediaType) || utils.Contains(conf.Charset.MediaTypes, mediaType)
+	}
+	// the stored ETags are keyed by the size and modification time of the files in the respective filesystem
+	cacheOpts := server.CacheOptions{Hash: eTagHash, MaxEntries: conf.ETag.MaxEntries, ContentDigest: conf.ETag.ContentDigest, FS: zipfs}
+	staticZipHandler := server.CachingWithOptions(cacheOpts)(server.FileServer(zipfs))
+	cacheOpts.FS = unzipfs
+	serveStaticZip := func(w http.ResponseWriter, r *http.Request) {
+		if conf.Log.AccessLog.CompressionRatio {
+			// the pre-zipped files are not compressed on the fly, the uncompressed size is taken from the unzipped filesystem
+			if info, err := fs.Stat(unzipfs, strings.TrimPrefix(r.URL.Path, "/")); err == nil && !info.IsDir() {
+				server.ReportUncompressedSize(r.Context(), info.Size())
+			}
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		staticZipHandler.ServeHTTP(w, r)
+	}
+	compressionStats := server.Optional(server.CompressionStats(), conf.Log.AccessLog.CompressionRatio)
+	dynamicZipHandler := server.CachingWithOptions(cacheOpts)(middleware.Compress(conf.Gzip.CompressionLevel, conf.Gzip.MediaTypes...)(
+		compressionStats(unzipHandler)))
 	var cspPathRegex *regexp.Regexp
 	var cspHandler http.Handler
 	if conf.AngularCspReplace.Enabled {
-		cspPathRegex = regexp.MustCompile(conf.AngularCspReplace.FilePathRegex)
-		cspHandler = middleware.Compress(gzip.DefaultCompression, conf.Gzip.MediaTypes...)(
-			server.CspFileReplace(conf.AngularCspReplace.VariableName, conf.MediaTypeMap)(unzipHandler))
+		cspPathRegex, err = regexp.Compile(conf.AngularCspReplace.FilePathRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid angular csp replace file path regex: %w", err)
+		}
+		cspHandler = middleware.Compress(conf.Gzip.CompressionLevel, conf.Gzip.MediaTypes...)(
+			compressionStats(server.CspFileReplaceWithMetrics(conf.AngularCspReplace.VariableName, conf.MediaTypeMap, resources.promRegistration)(unzipHandler)))
 	}
 	r.Handle("/*", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if cspPathRegex != nil && cspPathRegex.MatchString(r.URL.Path) {
 			cspHandler.ServeHTTP(w, r)
 			return
 		}
-		if conf.MemoryFs && conf.Gzip.Enabled {
-			if r.URL.Path == conf.FallbackPath {
-				w.Header().Set("Content-Encoding", "gzip")
-				staticZipHandler.ServeHTTP(w, r)
-				return
-			}
+		if zipfs != nil {
 			mediaType, ok := conf.MediaTypeMap[path.Ext(r.URL.Path)]
 			if i := strings.Index(mediaType, ";"); i >= 0 {
 				mediaType = mediaType[0:i]
 			}
-			if r.URL.Path == conf.FallbackPath || (ok && utils.Contains(conf.Gzip.MediaTypes, mediaType)) {
-				w.Header().Set("Content-Encoding", "gzip")
-				staticZipHandler.ServeHTTP(w, r)
+			transformed := isTransformed(r.URL.Path, mediaType)
+			if !transformed && r.URL.Path == conf.FallbackPath {
+				serveStaticZip(w, r)
+				return
+			}
+			if !transformed && ok && utils.Contains(conf.Gzip.MediaTypes, mediaType) {
+				serveStaticZip(w, r)
 				return
 			}
 		}
 		// gzip not active also will cause the gzipMediaTypes list to be empty so safe to call the generalized handler here
 		dynamicZipHandler.ServeHTTP(w, r)
 	}))
+	return r, nil
+}
 
-	webserver := server.Build(conf.Port.Webserver, time.Duration(conf.Timeout.Read)*time.Second,
-		time.Duration(conf.Timeout.Write)*time.Second, time.Duration(conf.Timeout.Idle)*time.Second, r)
-	log.Info().Msgf("Starting webserver server on port %d", conf.Port.Webserver)
-	srvCtx := context.WithValue(sigtermCtx, server.ServerName, "file server")
-	server.AddGracefulShutdown(srvCtx, &wg, webserver, time.Duration(conf.Timeout.Shutdown)*time.Second)
-	webserver.ListenGoServe(errChan)
-
-	if conf.Metrics.Enabled {
-		metricsServer := server.Build(conf.Port.Metrics, time.Duration(conf.Timeout.Read)*time.Second,
-			time.Duration(conf.Timeout.Write)*time.Second, time.Duration(conf.Timeout.Idle)*time.Second,
-			promhttp.Handler(), server.Optional(server.AccessLog(), conf.Log.AccessLog.Metrics))
-		metricsCtx := context.WithValue(sigtermCtx, server.ServerName, "prometheus metrics server")
-		server.AddGracefulShutdown(metricsCtx, &wg, metricsServer, time.Duration(conf.Timeout.Shutdown)*time.Second)
-		metricsServer.ListenGoServe(errChan)
-		log.Info().Msgf("Listening for prometheus metric scrapes under container port tcp/%s", metricsServer.Addr[1:])
+// reloadConfig reads the configuration again and swaps the webserver handler. Settings that require
+// a restart are only logged, the initial configuration is kept for them.
+func reloadConfig(initial *config, reloadable *server.ReloadableHandler, resources *handlerResources) {
+	updated, err := loadConfig()
+	if err != nil {
+		log.Error().Err(err).Msg("Error reloading configuration, keeping the previous one")
+		return
 	}
+	for _, setting := range restartRequired(initial, updated) {
+		log.Warn().Msgf("Changed setting %s requires a restart to take effect", setting)
+	}
+	keepRestartRequired(initial, updated)
+	handler, err := buildHandler(updated, resources)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid reloaded configuration, keeping the previous one")
+		return
+	}
+	reloadable.Swap(handler)
+	log.Info().Msg("Reloaded configuration")
+}
 
-	go logErrors(errChan)
-
-	// stop health server after everything else has stopped
-	if conf.Health {
-		healthServer := server.Build(conf.Port.Health, time.Duration(conf.Timeout.Read)*time.Second,
-			time.Duration(conf.Timeout.Write)*time.Second, time.Duration(conf.Timeout.Idle)*time.Second,
-			server.HealthCheckHandler(),
-			server.Optional(server.AccessLog(), conf.Log.AccessLog.Health),
-		)
-		log.Info().Msgf("Starting healthcheck server on port %d", conf.Port.Health)
-		healthCtx := context.WithValue(context.Background(), server.ServerName, "health server")
-		healthServer.ListenGoServe(errChan)
-		if err := landlockNetwork(ll); err != nil {
-			log.Fatal().Err(err).Msg("")
-		}
-		// 1 second is sufficient for health checks to shut down
-		errChan <- server.ShutdownAfterWaitGroup(healthCtx, &wg, healthServer.Server, time.Duration(1)*time.Second)
-	} else {
-		if err := landlockNetwork(ll); err != nil {
-			log.Fatal().Err(err).Msg("")
-		}
-		wg.Wait()
+// runValidation validates the configuration and target path, prints all found problems and returns the exit code
+func runValidation(conf *config) int {
+	args := flag.Args()
+	if len(args) != 1 {
+		_, _ = fmt.Fprintf(os.Stderr, "%v: %d\n", ErrInvalidNumberArguments, len(args))
+		return 1
 	}
+	if err := validateConfig(conf, args[0]); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		return 1
+	}
+	_, _ = fmt.Println("Configuration is valid")
+	return 0
 }
 
 // initFs loads the non-zipped and zipped fs according to the config
-// zipFs is nil if memoryFs or gzipActive are not set
+// zipFs is nil if neither memoryFs nor an archive target are used or gzipActive is not set
 func initFs(targetDir string, conf *config) (unzipfs fs.ReadFileFS, zipfs fs.ReadFileFS) {
-	if conf.MemoryFs {
+	symlinkPolicy, err := filesystem.SymlinkPolicyByName(conf.Symlinks)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error parsing the symlink configuration.")
+	}
+	var memoryFs *filesystem.MemoryFS
+	switch {
+	case filesystem.IsArchiveFile(targetDir):
+		log.Info().Msgf("Using the in-memory-filesystem from the archive %s", targetDir)
+		memoryFs, err = filesystem.NewArchiveFsWithOptions(targetDir, filesystem.ArchiveFsOptions{MaxBytes: conf.MemoryFsMaxBytes})
+	case conf.MemoryFs:
 		log.Info().Msg("Using the in-memory-filesystem")
-		memoryFs, err := filesystem.NewMemoryFs(targetDir)
+		memoryFs, err = filesystem.NewMemoryFsWithOptions(targetDir, filesystem.MemoryFsOptions{
+			Symlinks: symlinkPolicy,
+			Workers:  conf.MemoryFsWorkers,
+			MaxBytes: conf.MemoryFsMaxBytes,
+		})
+	default:
+		log.Info().Msg("Using the os filesystem")
+		unzipfs, err = filesystem.NewDirFs(targetDir, symlinkPolicy)
 		if err != nil {
 			log.Fatal().Err(err).Msg("Error preparing read-only filesystem.")
 		}
-		unzipfs = memoryFs
-		if conf.Gzip.Enabled {
-			log.Debug().Msg("Zipping in memory filesystem")
-			zipfs, err = memoryFs.Zip()
-			if err != nil {
-				log.Fatal().Err(err).Msg("Error preparing zipped read-only filesystem.")
-			}
+		return
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error preparing read-only filesystem.")
+	}
+	unzipfs = memoryFs
+	if conf.Gzip.Enabled {
+		log.Debug().Msg("Zipping in memory filesystem")
+		zipfs, err = memoryFs.Zip()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error preparing zipped read-only filesystem.")
 		}
-	} else {
-		log.Info().Msg("Using the os filesystem")
-		unzipfs = &filesystem.ReadFileFS{FS: os.DirFS(targetDir)}
 	}
 	return
 }
@@ -185,9 +493,26 @@ func logErrors(errChan <-chan error) {
 	}
 }
 
-// landlockReadonlyDir restricts file system access to only readonly permissions for the specified directory
-func landlockFsReadonlyDir(ll landlock.Config, target string) error {
-	if err := ll.RestrictPaths(landlock.RODirs(target)); err != nil {
+// landlockFs restricts file system access to only readonly permissions for the specified directory.
+// The files in the directory of the config file stay readable to support configuration reloads,
+// also if the config file is replaced like for mounted Kubernetes ConfigMaps. The directory of the access log file
+// is created if necessary and stays writable to support the log rotation.
+func landlockFs(ll landlock.Config, target string, configFile string, accessLogFile string) error {
+	rules := []landlock.Rule{landlock.RODirs(target)}
+	if filesystem.IsArchiveFile(target) {
+		rules = []landlock.Rule{landlock.ROFiles(target)}
+	}
+	if configFile != "" {
+		rules = append(rules, landlock.ROFiles(filepath.Dir(configFile)))
+	}
+	if accessLogFile != "" {
+		accessLogDir := filepath.Dir(accessLogFile)
+		if err := os.MkdirAll(accessLogDir, 0o750); err != nil {
+			return fmt.Errorf("error creating access log directory: %w", err)
+		}
+		rules = append(rules, landlock.RWDirs(accessLogDir))
+	}
+	if err := ll.RestrictPaths(rules...); err != nil {
 		return fmt.Errorf("error during landlock filesystem restriction: %w", err)
 	}
 	return nil
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReloadConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig := func(content string) {
+		require.NoError(t, os.WriteFile(configPath, []byte(content), 0o600))
+	}
+	originalConfFile := *confFile
+	*confFile = configPath
+	t.Cleanup(func() { *confFile = originalConfFile })
+
+	writeConfig("headers:\n  X-Test: old\n")
+	initial, err := loadConfig()
+	require.NoError(t, err)
+	fsys := fstest.MapFS{"index.html": &fstest.MapFile{Data: []byte("hi")}}
+	resources := &handlerResources{unzipfs: fsys}
+	handler, err := buildHandler(initial, resources)
+	require.NoError(t, err)
+	reloadable := server.NewReloadableHandler(handler)
+	require.Equal(t, "old", getTestHeader(reloadable))
+
+	writeConfig("headers:\n  X-Test: new\nport:\n  webserver: 1234\n")
+	reloadConfig(initial, reloadable, resources)
+	require.Equal(t, "new", getTestHeader(reloadable))
+	require.Equal(t, []string{"port"}, restartRequired(initial, mustLoadConfig(t)))
+
+	// invalid configurations keep the previous handler
+	writeConfig("headers:\n  X-Test: invalid\netag:\n  algorithm: unknown\n")
+	reloadConfig(initial, reloadable, resources)
+	require.Equal(t, "new", getTestHeader(reloadable))
+}
+
+func TestBuildHandlerRejectsInvalidFixedResponses(t *testing.T) {
+	resources := &handlerResources{unzipfs: fstest.MapFS{"index.html": &fstest.MapFile{Data: []byte("hi")}}}
+	for _, invalid := range [][]fixedResponseConfig{
+		{{Path: "/health", Status: 50}},
+		{{Path: "/health"}, {Path: "/health", Body: "duplicate"}},
+		{{Path: "/a/../health"}},
+	} {
+		conf := defaultConfig
+		conf.FixedResponses = invalid
+		_, err := buildHandler(&conf, resources)
+		require.ErrorIs(t, err, ErrInvalidFixedResponse)
+	}
+}
+
+func TestBuildHandlerSharesValidation(t *testing.T) {
+	resources := &handlerResources{unzipfs: fstest.MapFS{"index.html": &fstest.MapFile{Data: []byte("hi")}}}
+	for _, invalid := range []struct {
+		modify func(conf *config)
+		err    error
+	}{
+		{func(conf *config) { conf.Index = []string{"docs/index.html"} }, ErrInvalidIndexFile},
+		{func(conf *config) { conf.AllowedExtensions = []string{"js"} }, ErrInvalidExtension},
+		{func(conf *config) { conf.CircuitBreaker = circuitBreakerConfig{Enabled: true, Threshold: 5} }, ErrInvalidCircuitBreaker},
+	} {
+		conf := defaultConfig
+		invalid.modify(&conf)
+		require.ErrorIs(t, validateConfig(&conf, t.TempDir()), invalid.err)
+		_, err := buildHandler(&conf, resources)
+		require.ErrorIs(t, err, invalid.err)
+	}
+}
+
+func TestKeepRestartRequired(t *testing.T) {
+	initial := defaultConfig
+	updated := defaultConfig
+	updated.Port.Webserver = 1234
+	updated.MemoryFs = !initial.MemoryFs
+	updated.Headers = map[string]string{"X-Test": "new"}
+	require.ElementsMatch(t, []string{"port", "memoryfs"}, restartRequired(&initial, &updated))
+
+	keepRestartRequired(&initial, &updated)
+	require.Empty(t, restartRequired(&initial, &updated))
+	require.Equal(t, "new", updated.Headers["X-Test"])
+}
+
+// mustLoadConfig loads the configuration and fails the test on errors
+func mustLoadConfig(t *testing.T) *config {
+	conf, err := loadConfig()
+	require.NoError(t, err)
+	return conf
+}
+
+// getTestHeader returns the X-Test HTTP-Header of the handler response
+func getTestHeader(handler http.Handler) string {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/index.html", nil))
+	return w.Header().Get("X-Test")
+}
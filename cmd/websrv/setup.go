@@ -10,9 +10,13 @@ import (
 	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/providers/structs"
 	"github.com/knadh/koanf/v2"
+	"github.com/ngergs/websrv/v3/server"
 	"github.com/rs/zerolog"
+	"net/http"
 	"os"
+	"reflect"
 	"strings"
+	"time"
 
 	stdlog "log"
 
@@ -22,12 +26,33 @@ import (
 var (
 	ErrInvalidLogLevel        = errors.New("invalid loglevel, only error, warn, info and debug are valid")
 	ErrInvalidNumberArguments = errors.New("invalid number of argument, has to be 1")
+	ErrInvalidCompression     = errors.New("invalid gzip compression level, has to be between 1 and 9")
 
 	version = "snapshot"
 )
 
-// readConfig reads the configuration. Order is (least one takes precedence) defaults > config file > env vars.
+// Development only settings. These can only be set via command line flags and not via config file or env vars
+// to prevent enabling them accidentally in production.
+var (
+	devLatency   = flag.Duration("dev-latency", 0, "development only: artificial latency added to each response")
+	devBandwidth = flag.Int("dev-bandwidth", 0, "development only: throttles each response body to the given bytes per second, 0 disables")
+	devSuggest   = flag.Bool("dev-suggest", false, "development only: suggests similar file names in HTTP 404 responses, reveals the filesystem structure")
+	devPprof     = flag.Bool("dev-pprof", false, "development only: serves the pprof profiles under /debug/pprof/ on the metrics port and labels them with the request method and path")
+)
+
+var (
+	confFile     = flag.String("conf", "", "config file to load")
+	validateOnly = flag.Bool("validate", false, "validates the configuration and target path, lists all problems and exits without serving")
+)
+
+// readConfig parses the command line flags and reads the configuration.
 func readConfig() (*config, error) {
+	flag.Parse()
+	return loadConfig()
+}
+
+// loadConfig reads the configuration. Order is (least one takes precedence) defaults > config file > env vars.
+func loadConfig() (*config, error) {
 	k := koanf.New(".")
 	var conf config
 
@@ -37,8 +62,6 @@ func readConfig() (*config, error) {
 	}
 
 	// Load config from file
-	confFile := flag.String("conf", "", "config file to load")
-	flag.Parse()
 	if *confFile != "" {
 		if err := k.Load(file.Provider(*confFile), yaml.Parser()); err != nil {
 			return nil, fmt.Errorf("error loading config file: %w", err)
@@ -79,6 +102,10 @@ func setup(conf *config) (string, error) {
 		flag.PrintDefaults()
 	}
 
+	// the same checks as for the reloads and the -validate mode
+	if err := checkConfig(conf); err != nil {
+		return "", err
+	}
 	switch conf.Log.Level {
 	case "error":
 		zerolog.SetGlobalLevel(zerolog.ErrorLevel)
@@ -88,8 +115,6 @@ func setup(conf *config) (string, error) {
 		zerolog.SetGlobalLevel(zerolog.InfoLevel)
 	case "debug":
 		zerolog.SetGlobalLevel(zerolog.DebugLevel)
-	default:
-		return "", fmt.Errorf("%w: %s", ErrInvalidLogLevel, conf.Log.Level)
 	}
 	if conf.Log.Pretty {
 		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
@@ -106,3 +131,120 @@ func setup(conf *config) (string, error) {
 
 	return args[0], nil
 }
+
+// restartSettings returns pointers to the settings that only take effect after a restart
+func restartSettings(conf *config) []struct {
+	name  string
+	value any
+} {
+	return []struct {
+		name  string
+		value any
+	}{
+		{"log.level", &conf.Log.Level},
+		{"log.pretty", &conf.Log.Pretty},
+		{"log.access.health", &conf.Log.AccessLog.Health},
+		{"log.access.metrics", &conf.Log.AccessLog.Metrics},
+		{"log.access.file", &conf.Log.AccessLog.File},
+		{"metrics", &conf.Metrics},
+		{"memoryfs", &conf.MemoryFs},
+		{"memoryfsworkers", &conf.MemoryFsWorkers},
+		{"memoryfsmaxbytes", &conf.MemoryFsMaxBytes},
+		{"symlinks", &conf.Symlinks},
+		{"circuitbreaker.enabled", &conf.CircuitBreaker.Enabled},
+		{"circuitbreaker.threshold", &conf.CircuitBreaker.Threshold},
+		{"circuitbreaker.cooldown", &conf.CircuitBreaker.Cooldown},
+		{"health", &conf.Health},
+		{"healthwarmup", &conf.HealthWarmup},
+		{"port", &conf.Port},
+		{"gzip.enabled", &conf.Gzip.Enabled},
+		{"gzip.compression", &conf.Gzip.CompressionLevel},
+		{"timeout", &conf.Timeout},
+		{"shutdowndelay", &conf.ShutdownDelay},
+	}
+}
+
+// restartRequired returns the names of the settings that differ between the configurations and require a restart
+func restartRequired(initial *config, updated *config) []string {
+	var changed []string
+	updatedSettings := restartSettings(updated)
+	for i, setting := range restartSettings(initial) {
+		if !reflect.DeepEqual(setting.value, updatedSettings[i].value) {
+			changed = append(changed, setting.name)
+		}
+	}
+	return changed
+}
+
+// keepRestartRequired sets the settings that require a restart in the updated configuration to the initial values
+func keepRestartRequired(initial *config, updated *config) {
+	updatedSettings := restartSettings(updated)
+	for i, setting := range restartSettings(initial) {
+		reflect.ValueOf(updatedSettings[i].value).Elem().Set(reflect.ValueOf(setting.value).Elem())
+	}
+}
+
+// accessLogOptions converts the access log configuration to the corresponding server options.
+// A nil logger uses the global logger.
+func accessLogOptions(conf *accessLogConfig, logger *zerolog.Logger) (server.AccessLogOptions, error) {
+	options := server.DefaultAccessLogOptions
+	options.Logger = logger
+	options.CompressionRatio = conf.CompressionRatio
+	options.Protocol = conf.Protocol
+	options.ServedPath = conf.ServedPath
+	options.LatencyPhases = conf.LatencyPhases
+	for _, level := range []struct {
+		target *zerolog.Level
+		value  string
+	}{
+		{&options.Levels.Success, conf.Levels.Success},
+		{&options.Levels.Redirect, conf.Levels.Redirect},
+		{&options.Levels.ClientError, conf.Levels.ClientError},
+		{&options.Levels.ServerError, conf.Levels.ServerError},
+	} {
+		parsed, err := zerolog.ParseLevel(level.value)
+		if err != nil {
+			return options, fmt.Errorf("invalid access log level: %w", err)
+		}
+		*level.target = parsed
+	}
+	return options, nil
+}
+
+// fixedResponses converts the fixed response configuration to the server responses keyed by the request path
+func fixedResponses(confs []fixedResponseConfig) map[string]server.FixedResponse {
+	responses := make(map[string]server.FixedResponse, len(confs))
+	for _, conf := range confs {
+		response := server.FixedResponse{Status: conf.Status, ContentType: conf.ContentType, Body: conf.Body, Override: conf.Override}
+		if response.Status == 0 {
+			response.Status = http.StatusOK
+		}
+		if response.ContentType == "" {
+			response.ContentType = "text/plain; charset=utf-8"
+		}
+		responses[conf.Path] = response
+	}
+	return responses
+}
+
+// hstsOptions converts the HSTS configuration to the corresponding server options
+func hstsOptions(conf *hstsConfig) server.HstsOptions {
+	return server.HstsOptions{
+		MaxAge:              time.Duration(conf.MaxAge) * time.Second,
+		IncludeSubDomains:   conf.IncludeSubDomains,
+		Preload:             conf.Preload,
+		TrustForwardedProto: conf.TrustForwardedProto,
+	}
+}
+
+// accessLogFileOptions converts the access log file configuration to the corresponding server options
+func accessLogFileOptions(conf *accessLogFileConfig) server.AccessLogFileOptions {
+	return server.AccessLogFileOptions{
+		Path:           conf.Path,
+		MaxSizeMB:      conf.MaxSize,
+		MaxAgeDays:     conf.MaxAge,
+		MaxBackups:     conf.MaxBackups,
+		Compress:       conf.Compress,
+		RotateInterval: conf.RotateInterval,
+	}
+}
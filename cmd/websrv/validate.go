@@ -0,0 +1,159 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/ngergs/websrv/v3/filesystem"
+	"github.com/ngergs/websrv/v3/internal/utils"
+	"github.com/ngergs/websrv/v3/server"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrTargetDirNotDirectory = errors.New("target path is neither a directory nor a .zip, .tar.gz or .tgz archive")
+	ErrMissingVariableName   = errors.New("angular csp replace requires a variable name")
+	ErrInvalidIndexFile      = errors.New("index files have to be file names without a path")
+	ErrInvalidExtension      = errors.New("allowed extensions have to start with a dot and must not contain a path")
+	ErrInvalidFixedResponse  = errors.New("fixed responses require a unique clean absolute path and a status code between 200 and 599")
+	ErrInvalidCircuitBreaker = errors.New("circuit breaker requires a positive threshold and cooldown")
+)
+
+// validateConfig checks the configuration and the served directory without binding any ports.
+// All found problems are returned joined together.
+func validateConfig(conf *config, targetDir string) error {
+	errs := configErrors(conf)
+
+	var targetFs fs.FS
+	info, err := os.Stat(targetDir)
+	switch {
+	case err != nil:
+		errs = append(errs, fmt.Errorf("invalid target path: %w", err))
+	case filesystem.IsArchiveFile(targetDir):
+		archiveFs, err := filesystem.NewArchiveFsWithOptions(targetDir, filesystem.ArchiveFsOptions{MaxBytes: conf.MemoryFsMaxBytes})
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			targetFs = archiveFs
+		}
+	case !info.IsDir():
+		errs = append(errs, fmt.Errorf("%w: %s", ErrTargetDirNotDirectory, targetDir))
+	default:
+		targetFs = os.DirFS(targetDir)
+	}
+	if targetFs != nil && conf.FallbackPath != "" {
+		if err := server.ValidateFallback(targetFs, conf.FallbackPath); err != nil {
+			errs = append(errs, fmt.Errorf("invalid fallback configuration: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkConfig checks the configuration independent of the served directory. It is shared by the startup,
+// the reloads and the -validate mode, so that they accept the same configurations.
+func checkConfig(conf *config) error {
+	return errors.Join(configErrors(conf)...)
+}
+
+// configErrors returns all problems of the configuration that are independent of the served directory
+func configErrors(conf *config) []error {
+	var errs []error
+	if !utils.Contains([]string{"error", "warn", "info", "debug"}, conf.Log.Level) {
+		errs = append(errs, fmt.Errorf("%w: %s", ErrInvalidLogLevel, conf.Log.Level))
+	}
+	if conf.Gzip.CompressionLevel < gzip.BestSpeed || conf.Gzip.CompressionLevel > gzip.BestCompression {
+		errs = append(errs, fmt.Errorf("%w: %d", ErrInvalidCompression, conf.Gzip.CompressionLevel))
+	}
+	if _, err := accessLogOptions(&conf.Log.AccessLog, nil); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := server.HashFuncByName(conf.ETag.Algorithm); err != nil {
+		errs = append(errs, fmt.Errorf("invalid etag configuration: %w", err))
+	}
+	if _, err := filesystem.SymlinkPolicyByName(conf.Symlinks); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, indexFile := range conf.Index {
+		if indexFile == "" || strings.Contains(indexFile, "/") {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrInvalidIndexFile, indexFile))
+		}
+	}
+	if conf.CacheControl.HashedPath.Pattern != "" {
+		if _, err := regexp.Compile(conf.CacheControl.HashedPath.Pattern); err != nil {
+			errs = append(errs, fmt.Errorf("invalid hashed path pattern: %w", err))
+		}
+	}
+	for _, rule := range conf.CacheControl.Rules {
+		if _, err := regexp.Compile(rule.Path); err != nil {
+			errs = append(errs, fmt.Errorf("invalid cache control rule path: %w", err))
+		}
+	}
+	for _, downloadPath := range conf.Download.Paths {
+		if _, err := regexp.Compile(downloadPath); err != nil {
+			errs = append(errs, fmt.Errorf("invalid download path: %w", err))
+		}
+	}
+	for _, rule := range conf.LegalBlocks {
+		if _, err := regexp.Compile(rule.Path); err != nil {
+			errs = append(errs, fmt.Errorf("invalid legal block path: %w", err))
+		}
+	}
+	for _, pattern := range conf.Hidden.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("invalid hidden path pattern: %w", err))
+		}
+	}
+	for _, extension := range conf.AllowedExtensions {
+		if len(extension) < 2 || !strings.HasPrefix(extension, ".") || strings.Contains(extension, "/") {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrInvalidExtension, extension))
+		}
+	}
+	if err := checkFixedResponses(conf.FixedResponses); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkCircuitBreaker(&conf.CircuitBreaker); err != nil {
+		errs = append(errs, err)
+	}
+	for _, linkHeader := range conf.LinkHeaders {
+		if _, err := regexp.Compile(linkHeader.Path); err != nil {
+			errs = append(errs, fmt.Errorf("invalid link header path: %w", err))
+		}
+	}
+	if conf.AngularCspReplace.Enabled {
+		if _, err := regexp.Compile(conf.AngularCspReplace.FilePathRegex); err != nil {
+			errs = append(errs, fmt.Errorf("invalid angular csp replace file path regex: %w", err))
+		}
+		if conf.AngularCspReplace.VariableName == "" {
+			errs = append(errs, ErrMissingVariableName)
+		}
+	}
+	return errs
+}
+
+// checkFixedResponses checks that the fixed responses have unique clean absolute paths and valid status codes,
+// as net/http panics on invalid status codes while serving.
+func checkFixedResponses(confs []fixedResponseConfig) error {
+	var errs []error
+	paths := make(map[string]bool, len(confs))
+	for _, response := range confs {
+		if !path.IsAbs(response.Path) || path.Clean(response.Path) != response.Path || paths[response.Path] ||
+			(response.Status != 0 && (response.Status < 200 || response.Status > 599)) {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrInvalidFixedResponse, response.Path))
+		}
+		paths[response.Path] = true
+	}
+	return errors.Join(errs...)
+}
+
+// checkCircuitBreaker checks the thresholds of an enabled circuit breaker, a zero cooldown would keep it half-open
+func checkCircuitBreaker(conf *circuitBreakerConfig) error {
+	if conf.Enabled && (conf.Threshold < 1 || conf.Cooldown < 1) {
+		return fmt.Errorf("%w: threshold %d, cooldown %d", ErrInvalidCircuitBreaker, conf.Threshold, conf.Cooldown)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"github.com/ngergs/websrv/v3/filesystem"
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	targetDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(targetDir, "index.html"), []byte("hi"), 0o600))
+	conf := defaultConfig
+	conf.FallbackPath = "/"
+	require.NoError(t, validateConfig(&conf, targetDir))
+}
+
+func TestValidateConfigListsAllErrors(t *testing.T) {
+	conf := defaultConfig
+	conf.Log.Level = "verbose"
+	conf.Gzip.CompressionLevel = 10
+	conf.ETag.Algorithm = "md5"
+	conf.Symlinks = "ignore"
+	conf.Index = []string{"docs/index.html"}
+	conf.FallbackPath = "missing.html"
+	conf.CacheControl.HashedPath.Pattern = "("
+	conf.LinkHeaders = []linkHeaderConfig{{Path: "["}}
+
+	err := validateConfig(&conf, t.TempDir())
+	require.ErrorIs(t, err, ErrInvalidLogLevel)
+	require.ErrorIs(t, err, ErrInvalidCompression)
+	require.ErrorIs(t, err, server.ErrUnknownHashAlgorithm)
+	require.ErrorIs(t, err, filesystem.ErrUnknownSymlinkPolicy)
+	require.ErrorIs(t, err, server.ErrFallbackNotFound)
+	require.ErrorIs(t, err, ErrInvalidIndexFile)
+	var joined interface{ Unwrap() []error }
+	require.True(t, errors.As(err, &joined))
+	require.Len(t, joined.Unwrap(), 8)
+}
+
+func TestValidateConfigAllowedExtensions(t *testing.T) {
+	conf := defaultConfig
+	conf.AllowedExtensions = []string{".html", ".js"}
+	require.NoError(t, validateConfig(&conf, t.TempDir()))
+
+	for _, invalid := range []string{"js", ".", "", "./js"} {
+		conf.AllowedExtensions = []string{invalid}
+		require.ErrorIs(t, validateConfig(&conf, t.TempDir()), ErrInvalidExtension, invalid)
+	}
+}
+
+func TestValidateConfigFixedResponses(t *testing.T) {
+	conf := defaultConfig
+	conf.FixedResponses = []fixedResponseConfig{{Path: "/health"}, {Path: "/.well-known/security.txt", Status: 200}}
+	require.NoError(t, validateConfig(&conf, t.TempDir()))
+
+	for _, invalid := range []fixedResponseConfig{{Path: "health"}, {Path: "/a/../health"}, {Path: "/health", Status: 100}, {Path: "/health", Status: 600}} {
+		conf.FixedResponses = []fixedResponseConfig{invalid}
+		require.ErrorIs(t, validateConfig(&conf, t.TempDir()), ErrInvalidFixedResponse, invalid)
+	}
+	conf.FixedResponses = []fixedResponseConfig{{Path: "/health"}, {Path: "/health"}}
+	require.ErrorIs(t, validateConfig(&conf, t.TempDir()), ErrInvalidFixedResponse)
+}
+
+func TestValidateConfigTargetDir(t *testing.T) {
+	conf := defaultConfig
+	err := validateConfig(&conf, filepath.Join(t.TempDir(), "missing"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	targetFile := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(targetFile, []byte("hi"), 0o600))
+	err = validateConfig(&conf, targetFile)
+	require.ErrorIs(t, err, ErrTargetDirNotDirectory)
+}
+
+func TestValidateConfigArchive(t *testing.T) {
+	conf := defaultConfig
+	archivePath := filepath.Join(t.TempDir(), "site.zip")
+	require.NoError(t, os.WriteFile(archivePath, []byte("not a zip"), 0o600))
+	err := validateConfig(&conf, archivePath)
+	require.ErrorIs(t, err, filesystem.ErrInvalidArchive)
+}
+
+func TestValidateConfigCircuitBreaker(t *testing.T) {
+	conf := defaultConfig
+	conf.CircuitBreaker.Enabled = true
+	require.NoError(t, validateConfig(&conf, t.TempDir()))
+
+	conf.CircuitBreaker.Threshold = 0
+	require.ErrorIs(t, validateConfig(&conf, t.TempDir()), ErrInvalidCircuitBreaker)
+	conf.CircuitBreaker.Threshold = 5
+	conf.CircuitBreaker.Cooldown = 0
+	require.ErrorIs(t, validateConfig(&conf, t.TempDir()), ErrInvalidCircuitBreaker)
+}
@@ -0,0 +1,233 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ngergs/websrv/v3/internal/utils"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrUnsupportedArchive = errors.New("unsupported archive format, supported are .zip, .tar.gz and .tgz")
+	ErrInvalidArchive     = errors.New("invalid archive")
+)
+
+// IsArchive returns whether the file extension of the path is a supported archive format.
+func IsArchive(archivePath string) bool {
+	return strings.HasSuffix(archivePath, ".zip") || strings.HasSuffix(archivePath, ".tar.gz") || strings.HasSuffix(archivePath, ".tgz")
+}
+
+// IsArchiveFile returns whether the path is an existing file with a supported archive format.
+// Directories whose name looks like an archive, e.g. site.zip/, are no archive files.
+func IsArchiveFile(archivePath string) bool {
+	if !IsArchive(archivePath) {
+		return false
+	}
+	info, err := os.Stat(archivePath)
+	return err == nil && !info.IsDir()
+}
+
+// ArchiveFsOptions configures the loading of an archive into a MemoryFS.
+type ArchiveFsOptions struct {
+	// MaxBytes is the maximal total size of the extracted file contents, 0 disables the limit
+	MaxBytes int64
+}
+
+// NewArchiveFs reads the files of a .zip, .tar.gz or .tgz archive into a MemoryFS.
+// The archive is read completely, so corrupt archives are detected here, e.g. via the zip checksums.
+// Directories that are only implied by the file paths are added. Other entries than files and directories like symlinks are skipped.
+func NewArchiveFs(archivePath string) (*MemoryFS, error) {
+	return NewArchiveFsWithOptions(archivePath, ArchiveFsOptions{})
+}
+
+// NewArchiveFsWithOptions reads the files of a .zip, .tar.gz or .tgz archive into a MemoryFS.
+// The MaxBytes limit is enforced while extracting and does not rely on the sizes stated in the archive.
+func NewArchiveFsWithOptions(archivePath string, options ArchiveFsOptions) (*MemoryFS, error) {
+	builder := &archiveFsBuilder{files: make(map[string]*memoryFile), maxBytes: options.MaxBytes}
+	var err error
+	switch {
+	case strings.HasSuffix(archivePath, ".zip"):
+		err = builder.readZip(archivePath)
+	case strings.HasSuffix(archivePath, ".tar.gz") || strings.HasSuffix(archivePath, ".tgz"):
+		err = builder.readTarGz(archivePath)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedArchive, archivePath)
+	}
+	if errors.Is(err, ErrMemoryFsTooLarge) {
+		return nil, fmt.Errorf("%s: %w", archivePath, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidArchive, archivePath, err)
+	}
+	return builder.build(), nil
+}
+
+// archiveFsBuilder collects the archive entries for a MemoryFS
+type archiveFsBuilder struct {
+	files      map[string]*memoryFile
+	maxBytes   int64
+	totalBytes int64
+}
+
+func (builder *archiveFsBuilder) readZip(archivePath string) error {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer utils.Close(context.Background(), reader)
+	for _, file := range reader.File {
+		info := file.FileInfo()
+		if info.IsDir() {
+			if err := builder.add(file.Name, info, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			log.Warn().Msgf("Skipped non-regular archive entry %s", file.Name)
+			continue
+		}
+		data, err := builder.readZipFile(file)
+		if err != nil {
+			return err
+		}
+		if err := builder.add(file.Name, info, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readZipFile reads the file content, the checksum is verified once the content has been read completely.
+func (builder *archiveFsBuilder) readZipFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer utils.Close(context.Background(), reader)
+	return builder.read(reader)
+}
+
+// read reads the file content and fails with ErrMemoryFsTooLarge once the total size exceeds maxBytes.
+func (builder *archiveFsBuilder) read(reader io.Reader) ([]byte, error) {
+	if builder.maxBytes <= 0 {
+		return io.ReadAll(reader)
+	}
+	// reads at most one byte more than the remaining budget to detect that it is exceeded
+	data, err := io.ReadAll(io.LimitReader(reader, builder.maxBytes-builder.totalBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	builder.totalBytes += int64(len(data))
+	if builder.totalBytes > builder.maxBytes {
+		return nil, fmt.Errorf("%w: %d bytes", ErrMemoryFsTooLarge, builder.maxBytes)
+	}
+	return data, nil
+}
+
+func (builder *archiveFsBuilder) readTarGz(archivePath string) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer utils.Close(context.Background(), file)
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer utils.Close(context.Background(), gzipReader)
+	reader := tar.NewReader(gzipReader)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = builder.add(header.Name, header.FileInfo(), nil)
+		case tar.TypeReg:
+			var data []byte
+			data, err = builder.read(reader)
+			if err == nil {
+				err = builder.add(header.Name, header.FileInfo(), data)
+			}
+		default:
+			log.Warn().Msgf("Skipped non-regular archive entry %s", header.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// add stores the entry and the directories that are implied by its path. Paths that leave the archive root are rejected.
+func (builder *archiveFsBuilder) add(name string, info fs.FileInfo, data []byte) error {
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "./"), "/")
+	if name == "" || name == "." {
+		return nil
+	}
+	if !fs.ValidPath(name) {
+		return fmt.Errorf("invalid path %s", name)
+	}
+	if info.IsDir() {
+		builder.files[name] = &memoryFile{info: info}
+	} else {
+		builder.files[name] = &memoryFile{info: info, data: data}
+	}
+	for dir := path.Dir(name); ; dir = path.Dir(dir) {
+		if _, ok := builder.files[dir]; !ok {
+			builder.files[dir] = &memoryFile{info: &archiveDirInfo{name: path.Base(dir), modTime: info.ModTime()}}
+		}
+		if dir == "." {
+			return nil
+		}
+	}
+}
+
+// build adds the directory entries and returns the MemoryFS.
+func (builder *archiveFsBuilder) build() *MemoryFS {
+	if _, ok := builder.files["."]; !ok {
+		builder.files["."] = &memoryFile{info: &archiveDirInfo{name: "."}}
+	}
+	for name, file := range builder.files {
+		if name == "." {
+			continue
+		}
+		parent := builder.files[path.Dir(name)]
+		parent.dirInfo = append(parent.dirInfo, fs.FileInfoToDirEntry(file.info))
+	}
+	for _, file := range builder.files {
+		slices.SortFunc(file.dirInfo, func(a fs.DirEntry, b fs.DirEntry) int {
+			return strings.Compare(a.Name(), b.Name())
+		})
+	}
+	return &MemoryFS{files: builder.files}
+}
+
+// archiveDirInfo is the fs.FileInfo for directories that are only implied by the file paths in the archive.
+type archiveDirInfo struct {
+	name    string
+	modTime time.Time
+}
+
+func (info *archiveDirInfo) Name() string       { return info.name }
+func (info *archiveDirInfo) Size() int64        { return 0 }
+func (info *archiveDirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0o555 }
+func (info *archiveDirInfo) ModTime() time.Time { return info.modTime }
+func (info *archiveDirInfo) IsDir() bool        { return true }
+func (info *archiveDirInfo) Sys() any           { return nil }
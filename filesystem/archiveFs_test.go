@@ -0,0 +1,132 @@
+package filesystem_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"github.com/ngergs/websrv/v3/filesystem"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var archiveFiles = map[string]string{
+	"index.html":          "index",
+	"assets/main.js":      "main",
+	"assets/img/logo.svg": "logo",
+}
+
+func TestArchiveFsZip(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "site.zip")
+	writeZip(t, archivePath, archiveFiles)
+	requireArchiveFs(t, archivePath)
+}
+
+func TestArchiveFsTarGz(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "site.tar.gz")
+	writeTarGz(t, archivePath, archiveFiles)
+	requireArchiveFs(t, archivePath)
+}
+
+func TestArchiveFsCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "site.zip")
+	require.NoError(t, os.WriteFile(zipPath, []byte("not a zip"), 0o600))
+	_, err := filesystem.NewArchiveFs(zipPath)
+	require.ErrorIs(t, err, filesystem.ErrInvalidArchive)
+
+	tarPath := filepath.Join(dir, "site.tgz")
+	require.NoError(t, os.WriteFile(tarPath, []byte("not a tar"), 0o600))
+	_, err = filesystem.NewArchiveFs(tarPath)
+	require.ErrorIs(t, err, filesystem.ErrInvalidArchive)
+
+	escapePath := filepath.Join(dir, "escape.zip")
+	writeZip(t, escapePath, map[string]string{"../secret.txt": "secret"})
+	_, err = filesystem.NewArchiveFs(escapePath)
+	require.ErrorIs(t, err, filesystem.ErrInvalidArchive)
+
+	_, err = filesystem.NewArchiveFs(filepath.Join(dir, "site.rar"))
+	require.ErrorIs(t, err, filesystem.ErrUnsupportedArchive)
+}
+
+func TestArchiveFsMaxBytes(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "site.zip")
+	writeZip(t, zipPath, archiveFiles)
+	tarPath := filepath.Join(dir, "site.tgz")
+	writeTarGz(t, tarPath, archiveFiles)
+	// the file contents of archiveFiles have 13 bytes in total
+	for _, archivePath := range []string{zipPath, tarPath} {
+		_, err := filesystem.NewArchiveFsWithOptions(archivePath, filesystem.ArchiveFsOptions{MaxBytes: 13})
+		require.NoError(t, err)
+		_, err = filesystem.NewArchiveFsWithOptions(archivePath, filesystem.ArchiveFsOptions{MaxBytes: 12})
+		require.ErrorIs(t, err, filesystem.ErrMemoryFsTooLarge)
+	}
+}
+
+func TestIsArchiveFile(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "site.zip")
+	writeZip(t, zipPath, archiveFiles)
+	require.True(t, filesystem.IsArchiveFile(zipPath))
+	dirPath := filepath.Join(dir, "site.tgz")
+	require.NoError(t, os.Mkdir(dirPath, 0o700))
+	require.True(t, filesystem.IsArchive(dirPath))
+	require.False(t, filesystem.IsArchiveFile(dirPath))
+	require.False(t, filesystem.IsArchiveFile(filepath.Join(dir, "missing.zip")))
+}
+
+// requireArchiveFs checks that the archive is served with the archiveFiles including the implied directories
+func requireArchiveFs(t *testing.T, archivePath string) {
+	require.True(t, filesystem.IsArchive(archivePath))
+	archiveFs, err := filesystem.NewArchiveFs(archivePath)
+	require.NoError(t, err)
+	for name, content := range archiveFiles {
+		data, err := archiveFs.ReadFile(name)
+		require.NoError(t, err)
+		require.Equal(t, content, string(data))
+		info, err := fs.Stat(archiveFs, name)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(content)), info.Size())
+	}
+	root, err := fs.ReadDir(archiveFs, ".")
+	require.NoError(t, err)
+	require.Len(t, root, 2)
+	entries, err := fs.ReadDir(archiveFs, "assets")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "img", entries[0].Name())
+	require.True(t, entries[0].IsDir())
+}
+
+func writeZip(t *testing.T, archivePath string, files map[string]string) {
+	file, err := os.Create(archivePath)
+	require.NoError(t, err)
+	writer := zip.NewWriter(file)
+	for name, content := range files {
+		w, err := writer.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	require.NoError(t, file.Close())
+}
+
+func writeTarGz(t *testing.T, archivePath string, files map[string]string) {
+	file, err := os.Create(archivePath)
+	require.NoError(t, err)
+	gzipWriter := gzip.NewWriter(file)
+	writer := tar.NewWriter(gzipWriter)
+	for name, content := range files {
+		require.NoError(t, writer.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err = writer.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	require.NoError(t, gzipWriter.Close())
+	require.NoError(t, file.Close())
+}
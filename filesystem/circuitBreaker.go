@@ -0,0 +1,258 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+var (
+	ErrCircuitOpen = errors.New("filesystem circuit breaker is open")
+
+	// make sure that we implement the fs.ReadFileFS and ContextFS interfaces
+	_ fs.ReadFileFS = &CircuitBreakerFS{}
+	_ ContextFS     = &CircuitBreakerFS{}
+)
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed passes all file accesses to the backend.
+	CircuitClosed CircuitState = iota
+	// CircuitHalfOpen passes a single trial file access to the backend to test whether it has recovered.
+	CircuitHalfOpen
+	// CircuitOpen rejects all file accesses with ErrCircuitOpen until the cooldown has passed.
+	CircuitOpen
+)
+
+func (state CircuitState) String() string {
+	switch state {
+	case CircuitClosed:
+		return "closed"
+	case CircuitHalfOpen:
+		return "half-open"
+	case CircuitOpen:
+		return "open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(state))
+	}
+}
+
+// CircuitBreakerOptions configure a CircuitBreaker.
+type CircuitBreakerOptions struct {
+	// Threshold is the number of consecutive failed file accesses after which the circuit opens.
+	Threshold int
+	// Cooldown is the time after which an open circuit becomes half-open. Has to be positive, as an open circuit
+	// would otherwise immediately become half-open again.
+	Cooldown time.Duration
+}
+
+// CircuitBreaker tracks the failed file accesses of one or more CircuitBreakerFS that share a backend.
+// Missing files, rejected permissions, invalid paths and cancelled contexts are expected errors and do not count as failure.
+// After Threshold consecutive failures the circuit opens and file accesses fail with ErrCircuitOpen without touching the backend.
+// After the Cooldown the circuit is half-open and a single trial access is passed to the backend.
+// The circuit closes if the trial succeeds and opens again for another cooldown otherwise.
+// A file access only succeeds once the file has been closed without a read error, as network-backed filesystems
+// may also fail after the file has been opened.
+type CircuitBreaker struct {
+	opts     CircuitBreakerOptions
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// NewCircuitBreaker returns a closed CircuitBreaker. A Threshold below one is treated as one.
+func NewCircuitBreaker(opts CircuitBreakerOptions) *CircuitBreaker {
+	opts.Threshold = max(opts.Threshold, 1)
+	return &CircuitBreaker{opts: opts}
+}
+
+// State returns the current state. An open circuit whose cooldown has passed is reported as half-open.
+func (breaker *CircuitBreaker) State() CircuitState {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	if breaker.state == CircuitOpen && breaker.cooledDown() {
+		return CircuitHalfOpen
+	}
+	return breaker.state
+}
+
+// Blocked returns whether file accesses are currently rejected and the time after which they should be retried.
+// This is the case for an open circuit during the cooldown and for a half-open circuit while the trial access is pending.
+func (breaker *CircuitBreaker) Blocked() (retryAfter time.Duration, blocked bool) {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	switch {
+	case breaker.state == CircuitOpen && !breaker.cooledDown():
+		return breaker.openedAt.Add(breaker.opts.Cooldown).Sub(time.Now()), true
+	case breaker.state == CircuitHalfOpen && breaker.trial:
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// FS wraps the filesystem so that its file accesses are tracked and short-circuited by the breaker.
+func (breaker *CircuitBreaker) FS(fsys fs.FS) *CircuitBreakerFS {
+	return &CircuitBreakerFS{fsys: fsys, breaker: breaker}
+}
+
+func (breaker *CircuitBreaker) cooledDown() bool {
+	return !time.Now().Before(breaker.openedAt.Add(breaker.opts.Cooldown))
+}
+
+// allow returns whether a file access may be passed to the backend. The first access after the cooldown becomes the trial.
+func (breaker *CircuitBreaker) allow() bool {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	switch breaker.state {
+	case CircuitOpen:
+		if !breaker.cooledDown() {
+			return false
+		}
+		breaker.state = CircuitHalfOpen
+		breaker.trial = true
+		return true
+	case CircuitHalfOpen:
+		if breaker.trial {
+			return false
+		}
+		breaker.trial = true
+		return true
+	default:
+		return true
+	}
+}
+
+// report records the result of a file access.
+func (breaker *CircuitBreaker) report(err error) {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	if breaker.state == CircuitOpen {
+		// late results of accesses that started before the circuit opened
+		return
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// says nothing about the backend, another access becomes the trial
+		breaker.trial = false
+		return
+	}
+	if isBackendFailure(err) {
+		breaker.failures++
+		if breaker.state == CircuitHalfOpen || breaker.failures >= breaker.opts.Threshold {
+			breaker.state = CircuitOpen
+			breaker.openedAt = time.Now()
+			breaker.failures = 0
+			breaker.trial = false
+		}
+		return
+	}
+	breaker.state = CircuitClosed
+	breaker.failures = 0
+	breaker.trial = false
+}
+
+// isBackendFailure returns whether the error indicates a failing backend, expected errors like missing files do not.
+func isBackendFailure(err error) bool {
+	return err != nil && err != io.EOF && !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, fs.ErrPermission) &&
+		!errors.Is(err, fs.ErrInvalid)
+}
+
+// CircuitBreakerFS is a filesystem whose file accesses are tracked and short-circuited by a CircuitBreaker.
+// Open and Read errors of the files count as failures. The opened files have to be closed to report a successful access.
+type CircuitBreakerFS struct {
+	fsys    fs.FS
+	breaker *CircuitBreaker
+}
+
+// Open opens the named file or fails with ErrCircuitOpen if the circuit is open.
+func (f *CircuitBreakerFS) Open(name string) (fs.File, error) {
+	return f.open(f.fsys, name)
+}
+
+// OpenContext opens the named file with the context if the wrapped filesystem is a ContextFS.
+func (f *CircuitBreakerFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	return f.open(WithContext(ctx, f.fsys), name)
+}
+
+func (f *CircuitBreakerFS) open(fsys fs.FS, name string) (fs.File, error) {
+	if !f.breaker.allow() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: ErrCircuitOpen}
+	}
+	file, err := fsys.Open(name)
+	if err != nil {
+		f.breaker.report(err)
+		return nil, err
+	}
+	// the result is reported on the first read error or when the file is closed
+	return &circuitBreakerFile{File: file, breaker: f.breaker}, nil
+}
+
+// ReadFile reads the named file or fails with ErrCircuitOpen if the circuit is open.
+func (f *CircuitBreakerFS) ReadFile(name string) ([]byte, error) {
+	file, err := f.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// circuitBreakerFile reports the first read error or the successful access on Close to the breaker.
+type circuitBreakerFile struct {
+	fs.File
+	breaker  *CircuitBreaker
+	reported bool
+}
+
+func (file *circuitBreakerFile) Read(p []byte) (int, error) {
+	n, err := file.File.Read(p)
+	file.reportError(err)
+	return n, err
+}
+
+// Close reports the access as successful if no read error has been reported, failures of the Close itself also count.
+func (file *circuitBreakerFile) Close() error {
+	err := file.File.Close()
+	if !file.reported {
+		file.reported = true
+		file.breaker.report(err)
+	}
+	return err
+}
+
+// reportError reports the first read error, EOF is no error
+func (file *circuitBreakerFile) reportError(err error) {
+	if err == nil || err == io.EOF || file.reported {
+		return
+	}
+	file.reported = true
+	file.breaker.report(err)
+}
+
+// Seek is needed by http.FileServer for range requests and the content type detection.
+func (file *circuitBreakerFile) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := file.File.(io.Seeker)
+	if !ok {
+		return 0, &fs.PathError{Op: "seek", Err: errors.ErrUnsupported}
+	}
+	return seeker.Seek(offset, whence)
+}
+
+// ReadDir is needed by http.FileServer for the directory listings.
+func (file *circuitBreakerFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	dir, ok := file.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Err: errors.ErrUnsupported}
+	}
+	entries, err := dir.ReadDir(n)
+	file.reportError(err)
+	return entries, err
+}
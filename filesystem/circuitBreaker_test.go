@@ -0,0 +1,198 @@
+package filesystem_test
+
+import (
+	"errors"
+	"github.com/ngergs/websrv/v3/filesystem"
+	"io"
+	"io/fs"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const circuitBreakerCooldown = 20 * time.Millisecond
+
+var errBackend = errors.New("backend unavailable")
+
+// failingFs fails all Open calls with errBackend while failing is set, all Read calls while readFailing is set
+// and counts the Open calls that reach it
+type failingFs struct {
+	fsys        fs.FS
+	failing     atomic.Bool
+	readFailing atomic.Bool
+	opens       atomic.Int32
+}
+
+func (f *failingFs) Open(name string) (fs.File, error) {
+	f.opens.Add(1)
+	if f.failing.Load() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errBackend}
+	}
+	file, err := f.fsys.Open(name)
+	if err != nil || !f.readFailing.Load() {
+		return file, err
+	}
+	return &failingReadFile{File: file}, nil
+}
+
+// failingReadFile fails all Read calls with errBackend
+type failingReadFile struct {
+	fs.File
+}
+
+func (file *failingReadFile) Read([]byte) (int, error) {
+	return 0, errBackend
+}
+
+func getCircuitBreakerFs() (*filesystem.CircuitBreaker, *filesystem.CircuitBreakerFS, *failingFs) {
+	backend := &failingFs{fsys: fstest.MapFS{"index.html": {Data: []byte("test")}}}
+	breaker := filesystem.NewCircuitBreaker(filesystem.CircuitBreakerOptions{Threshold: 2, Cooldown: circuitBreakerCooldown})
+	return breaker, breaker.FS(backend), backend
+}
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	breaker, breakerFs, backend := getCircuitBreakerFs()
+	backend.failing.Store(true)
+
+	_, err := breakerFs.Open("index.html")
+	require.ErrorIs(t, err, errBackend)
+	require.Equal(t, filesystem.CircuitClosed, breaker.State())
+	_, err = breakerFs.Open("index.html")
+	require.ErrorIs(t, err, errBackend)
+	require.Equal(t, filesystem.CircuitOpen, breaker.State())
+	retryAfter, blocked := breaker.Blocked()
+	require.True(t, blocked)
+	require.Positive(t, retryAfter)
+	require.LessOrEqual(t, retryAfter, circuitBreakerCooldown)
+
+	// the backend is not accessed while the circuit is open
+	_, err = breakerFs.ReadFile("index.html")
+	require.ErrorIs(t, err, filesystem.ErrCircuitOpen)
+	require.Equal(t, int32(2), backend.opens.Load())
+}
+
+func TestCircuitBreakerReadFailures(t *testing.T) {
+	breaker, breakerFs, backend := getCircuitBreakerFs()
+	backend.readFailing.Store(true)
+
+	// the files open successfully, but every read fails
+	_, err := breakerFs.ReadFile("index.html")
+	require.ErrorIs(t, err, errBackend)
+	require.Equal(t, filesystem.CircuitClosed, breaker.State())
+	_, err = breakerFs.ReadFile("index.html")
+	require.ErrorIs(t, err, errBackend)
+	require.Equal(t, filesystem.CircuitOpen, breaker.State())
+	_, err = breakerFs.ReadFile("index.html")
+	require.ErrorIs(t, err, filesystem.ErrCircuitOpen)
+}
+
+func TestCircuitBreakerIgnoresExpectedErrors(t *testing.T) {
+	breaker, breakerFs, _ := getCircuitBreakerFs()
+	for range 3 {
+		_, err := breakerFs.Open("missing.html")
+		require.ErrorIs(t, err, fs.ErrNotExist)
+	}
+	require.Equal(t, filesystem.CircuitClosed, breaker.State())
+}
+
+func TestCircuitBreakerSuccessResetsFailures(t *testing.T) {
+	breaker, breakerFs, backend := getCircuitBreakerFs()
+	for range 3 {
+		backend.failing.Store(true)
+		_, err := breakerFs.Open("index.html")
+		require.ErrorIs(t, err, errBackend)
+		backend.failing.Store(false)
+		_, err = breakerFs.ReadFile("index.html")
+		require.NoError(t, err)
+	}
+	require.Equal(t, filesystem.CircuitClosed, breaker.State())
+}
+
+func TestCircuitBreakerHalfOpenCloses(t *testing.T) {
+	breaker, breakerFs, backend := getCircuitBreakerFs()
+	openCircuit(t, breakerFs, backend)
+
+	time.Sleep(circuitBreakerCooldown)
+	require.Equal(t, filesystem.CircuitHalfOpen, breaker.State())
+	_, blocked := breaker.Blocked()
+	require.False(t, blocked)
+
+	// the first access is the trial, further accesses are rejected until its file has been closed
+	trial, err := breakerFs.Open("index.html")
+	require.NoError(t, err)
+	data, err := io.ReadAll(trial)
+	require.NoError(t, err)
+	require.Equal(t, "test", string(data))
+	_, err = breakerFs.ReadFile("index.html")
+	require.ErrorIs(t, err, filesystem.ErrCircuitOpen)
+	require.Equal(t, filesystem.CircuitHalfOpen, breaker.State())
+	require.NoError(t, trial.Close())
+	require.Equal(t, filesystem.CircuitClosed, breaker.State())
+	_, err = breakerFs.ReadFile("index.html")
+	require.NoError(t, err)
+}
+
+func TestCircuitBreakerHalfOpenReopens(t *testing.T) {
+	breaker, breakerFs, backend := getCircuitBreakerFs()
+	openCircuit(t, breakerFs, backend)
+
+	time.Sleep(circuitBreakerCooldown)
+	require.Equal(t, filesystem.CircuitHalfOpen, breaker.State())
+	backend.failing.Store(true)
+	// a single failed trial suffices to open the circuit again
+	_, err := breakerFs.Open("index.html")
+	require.ErrorIs(t, err, errBackend)
+	require.Equal(t, filesystem.CircuitOpen, breaker.State())
+	_, err = breakerFs.Open("index.html")
+	require.ErrorIs(t, err, filesystem.ErrCircuitOpen)
+}
+
+func TestCircuitBreakerHalfOpenSingleTrial(t *testing.T) {
+	breaker, breakerFs, backend := getCircuitBreakerFs()
+	openCircuit(t, breakerFs, backend)
+	time.Sleep(circuitBreakerCooldown)
+
+	blockingBackend := &blockingFs{failingFs: backend, release: make(chan struct{})}
+	blockingBreakerFs := breaker.FS(blockingBackend)
+	trialDone := make(chan error)
+	go func() {
+		_, err := blockingBreakerFs.ReadFile("index.html")
+		trialDone <- err
+	}()
+	require.Eventually(t, func() bool {
+		_, blocked := breaker.Blocked()
+		return blocked
+	}, time.Second, time.Millisecond)
+	_, err := breakerFs.Open("index.html")
+	require.ErrorIs(t, err, filesystem.ErrCircuitOpen)
+
+	close(blockingBackend.release)
+	require.NoError(t, <-trialDone)
+	require.Equal(t, filesystem.CircuitClosed, breaker.State())
+}
+
+// blockingFs blocks the Open calls until release is closed
+type blockingFs struct {
+	*failingFs
+	release chan struct{}
+}
+
+func (f *blockingFs) Open(name string) (fs.File, error) {
+	<-f.release
+	return f.failingFs.Open(name)
+}
+
+// openCircuit opens the circuit with failed accesses and lets the backend recover afterward
+func openCircuit(t *testing.T, breakerFs *filesystem.CircuitBreakerFS, backend *failingFs) {
+	backend.failing.Store(true)
+	for range 2 {
+		_, err := breakerFs.Open("index.html")
+		require.ErrorIs(t, err, errBackend)
+	}
+	_, err := breakerFs.Open("index.html")
+	require.ErrorIs(t, err, filesystem.ErrCircuitOpen)
+	backend.failing.Store(false)
+}
@@ -0,0 +1,34 @@
+package filesystem
+
+import (
+	"context"
+	"io/fs"
+)
+
+// ContextFS is a fs.FS that supports cancellation of the file access, e.g. for network-backed filesystems.
+// The returned file should also abort pending reads once the context is done.
+type ContextFS interface {
+	fs.FS
+	OpenContext(ctx context.Context, name string) (fs.File, error)
+}
+
+// contextBoundFS binds a context to the Open calls of a ContextFS.
+type contextBoundFS struct {
+	fsys ContextFS
+	ctx  context.Context
+}
+
+// WithContext returns a fs.FS whose Open uses the OpenContext method with the given context if fsys is a ContextFS.
+// Other filesystems are returned unchanged.
+func WithContext(ctx context.Context, fsys fs.FS) fs.FS {
+	contextFs, ok := fsys.(ContextFS)
+	if !ok {
+		return fsys
+	}
+	return &contextBoundFS{fsys: contextFs, ctx: ctx}
+}
+
+// Open opens the named file with the bound context.
+func (f *contextBoundFS) Open(name string) (fs.File, error) {
+	return f.fsys.OpenContext(f.ctx, name)
+}
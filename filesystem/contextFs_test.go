@@ -0,0 +1,56 @@
+package filesystem_test
+
+import (
+	"context"
+	"github.com/ngergs/websrv/v3/filesystem"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+type contextKey struct{}
+
+// recordingContextFs records the context of the last OpenContext call
+type recordingContextFs struct {
+	fstest.MapFS
+	ctx context.Context
+}
+
+func (f *recordingContextFs) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	f.ctx = ctx
+	return f.Open(name)
+}
+
+func TestContextFsWrappersForward(t *testing.T) {
+	ctx := context.WithValue(context.Background(), contextKey{}, "request")
+	for name, wrap := range map[string]func(fs.FS) fs.FS{
+		"ReadFileFS": func(fsys fs.FS) fs.FS { return &filesystem.ReadFileFS{FS: fsys} },
+		"OverlayFS":  func(fsys fs.FS) fs.FS { return filesystem.NewOverlayFs(fstest.MapFS{}, fsys) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			backend := &recordingContextFs{MapFS: fstest.MapFS{"index.html": {Data: []byte("test")}}}
+			_, err := filesystem.WithContext(ctx, wrap(backend)).Open("index.html")
+			require.NoError(t, err)
+			require.Equal(t, ctx, backend.ctx)
+		})
+	}
+}
+
+func TestContextFsCancelled(t *testing.T) {
+	dirFs, err := filesystem.NewDirFs(testDir, filesystem.SymlinkFollow)
+	require.NoError(t, err)
+	memoryFs, err := filesystem.NewMemoryFs(testDir)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for name, fsys := range map[string]filesystem.ContextFS{"DirFS": dirFs, "MemoryFS": memoryFs} {
+		t.Run(name, func(t *testing.T) {
+			_, err := fsys.OpenContext(context.Background(), testFile)
+			require.NoError(t, err)
+			_, err = fsys.OpenContext(ctx, testFile)
+			require.ErrorIs(t, err, context.Canceled)
+		})
+	}
+}
@@ -11,17 +11,22 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 
 	"github.com/ngergs/websrv/v3/internal/utils"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
 )
 
 var (
 	ErrUnimplementedWhenceMode = errors.New("filesystem seek error: unsupported whence value")
 	ErrSeekedOutOfBounds       = errors.New("seeked out of bounds: file length and searched offset do not align")
+	ErrMemoryFsTooLarge        = errors.New("files exceed the in-memory-fs size limit")
 
-	// make sure that we implement the fs.ReadFileFS interface
+	// make sure that we implement the fs.ReadFileFS and ContextFS interfaces
 	_ fs.ReadFileFS = &MemoryFS{}
+	_ ContextFS     = &MemoryFS{}
 	_ io.ReaderAt   = &openMemoryFile{}
 	_ io.Seeker     = &openMemoryFile{}
 	_ fs.File       = &openMemoryFile{}
@@ -44,56 +49,121 @@ type openMemoryFile struct {
 	dirOffset  int
 }
 
-// NewMemoryFs initials a memory filesystem from the given targetPath
+// MemoryFsOptions configures the loading of a MemoryFS.
+type MemoryFsOptions struct {
+	// Symlinks is the policy for symlinks, symlinks that are not allowed are skipped
+	Symlinks SymlinkPolicy
+	// Workers is the number of files that are read in parallel, which also caps the number of concurrently opened files.
+	// Values below 1 read the files serially.
+	Workers int
+	// MaxBytes is the maximal total size of the file contents, 0 disables the limit
+	MaxBytes int64
+}
+
+// NewMemoryFs initials a memory filesystem from the given targetPath. Symlinks are only followed within the targetPath.
 func NewMemoryFs(targetPath string) (*MemoryFS, error) {
+	return NewMemoryFsWithOptions(targetPath, MemoryFsOptions{Symlinks: SymlinkWithinRoot, Workers: 1})
+}
+
+// NewMemoryFsWithOptions is like NewMemoryFs but applies the given options.
+// The contents of symlinked directories are not read.
+func NewMemoryFsWithOptions(targetPath string, options MemoryFsOptions) (*MemoryFS, error) {
 	targetPath = path.Clean(targetPath)
-	fs := &MemoryFS{
-		files: make(map[string]*memoryFile),
-	}
-	err := filepath.Walk(targetPath, getReadFileFunc(fs, len(targetPath)))
+	checker, err := newSymlinkChecker(targetPath, options.Symlinks)
 	if err != nil {
 		return nil, fmt.Errorf("error reading files into in-memory-fs: %w", err)
 	}
-	return fs, nil
+	loader := &memoryFsLoader{
+		filesystem:      &MemoryFS{files: make(map[string]*memoryFile)},
+		targetDirLength: len(targetPath),
+		checker:         checker,
+		maxBytes:        options.MaxBytes,
+	}
+	var ctx context.Context
+	loader.group, ctx = errgroup.WithContext(context.Background())
+	loader.group.SetLimit(max(options.Workers, 1))
+	walkErr := filepath.Walk(targetPath, func(filePath string, info fs.FileInfo, err error) error {
+		// stop walking once a file could not be read
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return loader.load(filePath, info, err)
+	})
+	// the error of the failed file read takes precedence over the resulting context cancellation
+	if err := loader.group.Wait(); err != nil {
+		return nil, fmt.Errorf("error reading files into in-memory-fs: %w", err)
+	}
+	if walkErr != nil {
+		return nil, fmt.Errorf("error reading files into in-memory-fs: %w", walkErr)
+	}
+	return loader.filesystem, nil
+}
+
+// memoryFsLoader reads the files of the walk into the MemoryFS. The directories are read during the walk,
+// the file contents concurrently via the errgroup.
+type memoryFsLoader struct {
+	filesystem      *MemoryFS
+	mutex           sync.Mutex
+	group           *errgroup.Group
+	targetDirLength int
+	checker         *symlinkChecker
+	maxBytes        int64
+	totalBytes      atomic.Int64
 }
 
-func getReadFileFunc(filesystem *MemoryFS, targetDirLength int) func(path string, info fs.FileInfo, err error) error {
-	return func(filePath string, info fs.FileInfo, err error) error {
-		// remove targetDir part and leading / from path
-		var subPath string
-		if len(filePath) > targetDirLength {
-			subPath = filePath[(targetDirLength + 1):]
-		} else {
-			subPath = "."
+func (loader *memoryFsLoader) load(filePath string, info fs.FileInfo, err error) error {
+	// remove targetDir part and leading / from path
+	var subPath string
+	if len(filePath) > loader.targetDirLength {
+		subPath = filePath[(loader.targetDirLength + 1):]
+	} else {
+		subPath = "."
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if err := loader.checker.check(filepath.ToSlash(subPath)); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				log.Warn().Err(err).Msgf("Skipped symlink for memory-fs: %s", subPath)
+				return nil
+			}
+			return err
 		}
+		// walk only provides the info of the symlink itself
+		info, err = os.Stat(filePath)
 		if err != nil {
 			return err
 		}
-		file, err := os.Open(filePath)
+	}
+	if info.IsDir() {
+		dirInfo, err := os.ReadDir(filePath)
 		if err != nil {
 			return err
 		}
-		defer utils.Close(context.Background(), file)
-
-		var result *memoryFile
-		if info.IsDir() {
-			dirInfo, err := file.ReadDir(0)
-			if err != nil {
-				return err
-			}
-			result = &memoryFile{info: info, dirInfo: dirInfo}
-		} else {
-			data, err := io.ReadAll(file)
-
-			if err != nil {
-				return err
-			}
-			result = &memoryFile{data: data, info: info}
-		}
-		log.Debug().Msgf("Read into memory-fs: %s", subPath)
-		filesystem.files[subPath] = result
+		loader.store(subPath, &memoryFile{info: info, dirInfo: dirInfo})
 		return nil
 	}
+	// reserve the budget before reading, so that concurrent reads can not exceed it
+	if total := loader.totalBytes.Add(info.Size()); loader.maxBytes > 0 && total > loader.maxBytes {
+		return fmt.Errorf("%w: %d bytes", ErrMemoryFsTooLarge, loader.maxBytes)
+	}
+	loader.group.Go(func() error {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		loader.store(subPath, &memoryFile{data: data, info: info})
+		return nil
+	})
+	return nil
+}
+
+func (loader *memoryFsLoader) store(subPath string, file *memoryFile) {
+	log.Debug().Msgf("Read into memory-fs: %s", subPath)
+	loader.mutex.Lock()
+	defer loader.mutex.Unlock()
+	loader.filesystem.files[subPath] = file
 }
 
 // Open opens the given file from the in memory filesystem.
@@ -105,6 +175,14 @@ func (f *MemoryFS) Open(name string) (fs.File, error) {
 	return &openMemoryFile{file: file}, nil
 }
 
+// OpenContext opens the given file from the in memory filesystem if the context is not done yet.
+func (f *MemoryFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return f.Open(name)
+}
+
 // ReadFile is a more efficient shortcut to read a complete file content from the in memory filesystem.
 func (f *MemoryFS) ReadFile(name string) ([]byte, error) {
 	file, ok := f.files[name]
@@ -125,11 +203,17 @@ func (mod *modifiedSizeInfo) Size() int64 {
 
 // Zip returns a deep copy of the filesystem where all files that match the given zip file extension are zipped.
 // Files that do not match are absent in the zipped version of the in memoryfilesystem.
+// The files are zipped with gzip.BestCompression.
 func (f *MemoryFS) Zip() (*MemoryFS, error) {
+	return f.ZipLevel(gzip.BestCompression)
+}
+
+// ZipLevel is like Zip but uses the given gzip compression level.
+func (f *MemoryFS) ZipLevel(level int) (*MemoryFS, error) {
 	zippedFiles := make(map[string]*memoryFile)
 	for filepath, file := range f.files {
 		log.Debug().Msgf("Zipping %s", filepath)
-		zipped, err := utils.Zip(file.data, gzip.BestCompression)
+		zipped, err := utils.Zip(file.data, level)
 		if err != nil {
 			return nil, err
 		}
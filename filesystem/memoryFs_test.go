@@ -8,6 +8,7 @@ import (
 	"io/fs"
 	"os"
 	"path"
+	"strconv"
 	"testing"
 
 	"github.com/ngergs/websrv/v3/internal/utils"
@@ -57,6 +58,23 @@ func TestMemoryFsZip(t *testing.T) {
 	require.Equal(t, originalDataZipped, memoryDataZipped)
 }
 
+// TestMemoryFsZipLevel tests that the configured compression level is used
+func TestMemoryFsZipLevel(t *testing.T) {
+	memoryFs, err := filesystem.NewMemoryFs(testDir)
+	require.NoError(t, err)
+	memoryFsZipped, err := memoryFs.ZipLevel(gzip.BestSpeed)
+	require.NoError(t, err)
+
+	originalData, err := os.ReadFile(path.Join(testDir, testFile))
+	require.NoError(t, err)
+	originalDataZipped, err := utils.Zip(originalData, gzip.BestSpeed)
+	require.NoError(t, err)
+
+	memoryDataZipped, err := memoryFsZipped.ReadFile(testFile)
+	require.NoError(t, err)
+	require.Equal(t, originalDataZipped, memoryDataZipped)
+}
+
 func getStatsContent(t *testing.T, fs fs.FS, path string) ([]byte, fs.FileInfo) {
 	file, err := fs.Open(path)
 	require.NoError(t, err)
@@ -67,3 +85,65 @@ func getStatsContent(t *testing.T, fs fs.FS, path string) ([]byte, fs.FileInfo)
 	require.NoError(t, err)
 	return data, stat
 }
+
+// TestMemoryFsParallel tests that the parallel loading reads the same files as the serial one
+func TestMemoryFsParallel(t *testing.T) {
+	dir := getWarmupDir(t, 50, 1024)
+	serialFs, err := filesystem.NewMemoryFsWithOptions(dir, filesystem.MemoryFsOptions{Workers: 1})
+	require.NoError(t, err)
+	parallelFs, err := filesystem.NewMemoryFsWithOptions(dir, filesystem.MemoryFsOptions{Workers: 8})
+	require.NoError(t, err)
+
+	err = fs.WalkDir(serialFs, ".", func(name string, d fs.DirEntry, err error) error {
+		require.NoError(t, err)
+		if d.IsDir() {
+			return nil
+		}
+		serialData, err := serialFs.ReadFile(name)
+		require.NoError(t, err)
+		parallelData, err := parallelFs.ReadFile(name)
+		require.NoError(t, err)
+		require.Equal(t, serialData, parallelData)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// TestMemoryFsMaxBytes tests that loading fails if the files exceed the size limit
+func TestMemoryFsMaxBytes(t *testing.T) {
+	dir := getWarmupDir(t, 10, 1024)
+	_, err := filesystem.NewMemoryFsWithOptions(dir, filesystem.MemoryFsOptions{Workers: 4, MaxBytes: 10 * 1024})
+	require.NoError(t, err)
+	_, err = filesystem.NewMemoryFsWithOptions(dir, filesystem.MemoryFsOptions{Workers: 4, MaxBytes: 10*1024 - 1})
+	require.ErrorIs(t, err, filesystem.ErrMemoryFsTooLarge)
+}
+
+func BenchmarkMemoryFsSerial(b *testing.B) {
+	benchmarkMemoryFs(b, 1)
+}
+
+func BenchmarkMemoryFsParallel(b *testing.B) {
+	benchmarkMemoryFs(b, 8)
+}
+
+func benchmarkMemoryFs(b *testing.B, workers int) {
+	dir := getWarmupDir(b, 500, 64*1024)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := filesystem.NewMemoryFsWithOptions(dir, filesystem.MemoryFsOptions{Workers: workers})
+		require.NoError(b, err)
+	}
+}
+
+// getWarmupDir returns a directory with the given number of files of the given size spread across subdirectories
+func getWarmupDir(tb testing.TB, files int, size int) string {
+	dir := tb.TempDir()
+	data := make([]byte, size)
+	for i := range files {
+		subDir := path.Join(dir, strconv.Itoa(i%10))
+		require.NoError(tb, os.MkdirAll(subDir, 0o755))
+		data[0] = byte(i)
+		require.NoError(tb, os.WriteFile(path.Join(subDir, strconv.Itoa(i)+".js"), data, 0o600))
+	}
+	return dir
+}
@@ -0,0 +1,58 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+)
+
+// make sure that we implement the fs.ReadFileFS and ContextFS interfaces
+var (
+	_ fs.ReadFileFS = &OverlayFS{}
+	_ ContextFS     = &OverlayFS{}
+)
+
+// OverlayFS combines multiple filesystems into a prioritized overlay.
+// A file is looked up in each layer in order and the first match wins.
+// Directory entries are not merged, a directory is served from the first layer that contains it.
+type OverlayFS struct {
+	layers []fs.FS
+}
+
+// NewOverlayFs returns an overlay of the given layers, earlier layers take precedence.
+func NewOverlayFs(layers ...fs.FS) *OverlayFS {
+	return &OverlayFS{layers: layers}
+}
+
+// Open opens the named file from the first layer that contains it.
+func (f *OverlayFS) Open(name string) (fs.File, error) {
+	return f.OpenContext(context.Background(), name)
+}
+
+// OpenContext opens the named file from the first layer that contains it with the context for the layers that are a ContextFS.
+func (f *OverlayFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	for _, layer := range f.layers {
+		file, err := WithContext(ctx, layer).Open(name)
+		if err == nil {
+			return file, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadFile reads the named file from the first layer that contains it.
+func (f *OverlayFS) ReadFile(name string) ([]byte, error) {
+	for _, layer := range f.layers {
+		data, err := fs.ReadFile(layer, name)
+		if err == nil {
+			return data, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrNotExist}
+}
@@ -0,0 +1,34 @@
+package filesystem_test
+
+import (
+	"github.com/ngergs/websrv/v3/filesystem"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlayFsPrecedence(t *testing.T) {
+	overrides := fstest.MapFS{"index.html": {Data: []byte("override")}}
+	base := fstest.MapFS{
+		"index.html": {Data: []byte("base")},
+		"main.js":    {Data: []byte("js")},
+	}
+	overlayFs := filesystem.NewOverlayFs(overrides, base)
+
+	data, err := overlayFs.ReadFile("index.html")
+	require.NoError(t, err)
+	require.Equal(t, "override", string(data))
+	data, err = fs.ReadFile(overlayFs, "main.js")
+	require.NoError(t, err)
+	require.Equal(t, "js", string(data))
+}
+
+func TestOverlayFsNotExist(t *testing.T) {
+	overlayFs := filesystem.NewOverlayFs(fstest.MapFS{}, fstest.MapFS{})
+	_, err := overlayFs.Open("index.html")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = overlayFs.ReadFile("index.html")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
@@ -1,12 +1,16 @@
 package filesystem
 
 import (
+	"context"
 	"io"
 	"io/fs"
 )
 
-// make sure that we implement the fs.ReadFileFS interface
-var _ fs.ReadFileFS = &ReadFileFS{}
+// make sure that we implement the fs.ReadFileFS and ContextFS interfaces
+var (
+	_ fs.ReadFileFS = &ReadFileFS{}
+	_ ContextFS     = &ReadFileFS{}
+)
 
 // ReadFileFS wraps a fs.FS and adds the ReadFile method
 type ReadFileFS struct {
@@ -14,11 +18,16 @@ type ReadFileFS struct {
 }
 
 // ReadFile is a more concise way to directly read a file into memory.
-func (fs *ReadFileFS) ReadFile(name string) ([]byte, error) {
-	file, err := fs.Open(name)
+func (f *ReadFileFS) ReadFile(name string) ([]byte, error) {
+	file, err := f.Open(name)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
 	return io.ReadAll(file)
 }
+
+// OpenContext opens the named file with the context if the wrapped filesystem is a ContextFS.
+func (f *ReadFileFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	return WithContext(ctx, f.FS).Open(name)
+}
@@ -0,0 +1,145 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrUnknownSymlinkPolicy = errors.New("unknown symlink policy")
+	// ErrSymlinkNotAllowed also matches fs.ErrNotExist so that rejected files are treated like absent ones
+	ErrSymlinkNotAllowed = fmt.Errorf("%w: symlink is not allowed", fs.ErrNotExist)
+
+	// make sure that we implement the fs.ReadFileFS and ContextFS interfaces
+	_ fs.ReadFileFS = &DirFS{}
+	_ ContextFS     = &DirFS{}
+)
+
+// SymlinkPolicy determines how symlinks below the root directory of a filesystem are handled.
+type SymlinkPolicy int
+
+const (
+	// SymlinkWithinRoot follows symlinks as long as they resolve to a path within the root directory.
+	SymlinkWithinRoot SymlinkPolicy = iota
+	// SymlinkDeny rejects all paths that contain a symlink. The root directory itself may be a symlink.
+	SymlinkDeny
+	// SymlinkFollow follows all symlinks, also those that point outside the root directory.
+	SymlinkFollow
+)
+
+// SymlinkPolicyByName returns the SymlinkPolicy for the names root, deny and follow.
+func SymlinkPolicyByName(name string) (SymlinkPolicy, error) {
+	switch name {
+	case "root":
+		return SymlinkWithinRoot, nil
+	case "deny":
+		return SymlinkDeny, nil
+	case "follow":
+		return SymlinkFollow, nil
+	default:
+		return 0, fmt.Errorf("%w: %s", ErrUnknownSymlinkPolicy, name)
+	}
+}
+
+// symlinkChecker checks paths relative to a root directory against a SymlinkPolicy.
+type symlinkChecker struct {
+	root         string
+	resolvedRoot string
+	policy       SymlinkPolicy
+}
+
+func newSymlinkChecker(root string, policy SymlinkPolicy) (*symlinkChecker, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+	return &symlinkChecker{root: root, resolvedRoot: resolvedRoot, policy: policy}, nil
+}
+
+// check returns ErrSymlinkNotAllowed if the slash-separated path relative to the root violates the policy.
+func (checker *symlinkChecker) check(name string) error {
+	switch checker.policy {
+	case SymlinkWithinRoot:
+		resolved, err := filepath.EvalSymlinks(filepath.Join(checker.root, filepath.FromSlash(name)))
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(checker.resolvedRoot, resolved)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return ErrSymlinkNotAllowed
+		}
+	case SymlinkDeny:
+		current := checker.root
+		for _, segment := range strings.Split(path.Clean(name), "/") {
+			if segment == "." {
+				continue
+			}
+			current = filepath.Join(current, segment)
+			info, err := os.Lstat(current)
+			if err != nil {
+				return err
+			}
+			if info.Mode()&fs.ModeSymlink != 0 {
+				return ErrSymlinkNotAllowed
+			}
+		}
+	case SymlinkFollow:
+	}
+	return nil
+}
+
+// DirFS is a filesystem for the files below a directory like os.DirFS that applies a SymlinkPolicy.
+// The check happens before the file is opened, so symlinks that are swapped concurrently are not detected.
+type DirFS struct {
+	fsys    fs.FS
+	checker *symlinkChecker
+}
+
+// NewDirFs returns a DirFS for the given root directory.
+func NewDirFs(root string, policy SymlinkPolicy) (*DirFS, error) {
+	checker, err := newSymlinkChecker(root, policy)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing filesystem: %w", err)
+	}
+	return &DirFS{fsys: os.DirFS(root), checker: checker}, nil
+}
+
+// Open opens the named file if it is allowed by the SymlinkPolicy.
+func (f *DirFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := f.checker.check(name); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return f.fsys.Open(name)
+}
+
+// OpenContext opens the named file if it is allowed by the SymlinkPolicy and the context is not done yet.
+func (f *DirFS) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return f.Open(name)
+}
+
+// ReadFile reads the named file if it is allowed by the SymlinkPolicy.
+func (f *DirFS) ReadFile(name string) ([]byte, error) {
+	file, err := f.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
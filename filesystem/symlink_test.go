@@ -0,0 +1,76 @@
+package filesystem_test
+
+import (
+	"github.com/ngergs/websrv/v3/filesystem"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// getSymlinkDir returns a root directory with a file, a symlink to it and a symlink that escapes the root directory
+func getSymlinkDir(t *testing.T) string {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	require.NoError(t, os.Mkdir(root, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "index.html"), []byte("index"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0o600))
+	require.NoError(t, os.Symlink("index.html", filepath.Join(root, "inside.html")))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(root, "escape.txt")))
+	require.NoError(t, os.Symlink("..", filepath.Join(root, "parent")))
+	return root
+}
+
+func TestSymlinkPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		policy  filesystem.SymlinkPolicy
+		allowed map[string]bool
+	}{
+		{name: "root", policy: filesystem.SymlinkWithinRoot,
+			allowed: map[string]bool{"index.html": true, "inside.html": true, "escape.txt": false, "parent/secret.txt": false}},
+		{name: "deny", policy: filesystem.SymlinkDeny,
+			allowed: map[string]bool{"index.html": true, "inside.html": false, "escape.txt": false, "parent/secret.txt": false}},
+		{name: "follow", policy: filesystem.SymlinkFollow,
+			allowed: map[string]bool{"index.html": true, "inside.html": true, "escape.txt": true, "parent/secret.txt": true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			root := getSymlinkDir(t)
+			dirFs, err := filesystem.NewDirFs(root, tc.policy)
+			require.NoError(t, err)
+			memoryFs, err := filesystem.NewMemoryFsWithOptions(root, filesystem.MemoryFsOptions{Symlinks: tc.policy})
+			require.NoError(t, err)
+			for name, allowed := range tc.allowed {
+				data, err := dirFs.ReadFile(name)
+				if allowed {
+					require.NoError(t, err, name)
+					require.NotEmpty(t, data)
+				} else {
+					require.ErrorIs(t, err, filesystem.ErrSymlinkNotAllowed, name)
+					require.ErrorIs(t, err, fs.ErrNotExist, name)
+				}
+				// the memory fs does not descend into symlinked directories
+				if filepath.Dir(name) == "." {
+					_, err = memoryFs.ReadFile(name)
+					require.Equal(t, allowed, err == nil, name)
+				}
+			}
+		})
+	}
+}
+
+func TestSymlinkPolicyByName(t *testing.T) {
+	for name, policy := range map[string]filesystem.SymlinkPolicy{
+		"root":   filesystem.SymlinkWithinRoot,
+		"deny":   filesystem.SymlinkDeny,
+		"follow": filesystem.SymlinkFollow,
+	} {
+		parsed, err := filesystem.SymlinkPolicyByName(name)
+		require.NoError(t, err)
+		require.Equal(t, policy, parsed)
+	}
+	_, err := filesystem.SymlinkPolicyByName("ignore")
+	require.ErrorIs(t, err, filesystem.ErrUnknownSymlinkPolicy)
+}
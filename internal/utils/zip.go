@@ -5,6 +5,7 @@ import (
 	"compress/gzip"
 	"github.com/rs/zerolog/log"
 	"io"
+	"sync"
 )
 
 // Unzip unzips the input byte slice.
@@ -32,21 +33,31 @@ func Unzip(in []byte) ([]byte, error) {
 	return result.Bytes(), nil
 }
 
+// gzipWriterPools holds a pool of gzip writers for each valid compression level from gzip.HuffmanOnly to gzip.BestCompression.
+var gzipWriterPools [gzip.BestCompression - gzip.HuffmanOnly + 1]sync.Pool
+
+// getGzipWriter returns a pooled gzip writer for the given level that has been reset to write to w.
+func getGzipWriter(w io.Writer, level int) (*gzip.Writer, error) {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		// gzip will return the appropriate error
+		return gzip.NewWriterLevel(w, level)
+	}
+	if gzipWriter, ok := gzipWriterPools[level-gzip.HuffmanOnly].Get().(*gzip.Writer); ok {
+		gzipWriter.Reset(w)
+		return gzipWriter, nil
+	}
+	return gzip.NewWriterLevel(w, level)
+}
+
 // Zip zips the input byte slice with the given gzip compression level.
+// The gzip writers are pooled per compression level to reduce allocations.
 func Zip(in []byte, level int) ([]byte, error) {
 	var result bytes.Buffer
-	inReader := bytes.NewReader(in)
-	gzipWriter, err := gzip.NewWriterLevel(&result, level)
+	gzipWriter, err := getGzipWriter(&result, level)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		err := gzipWriter.Close()
-		if err != nil {
-			log.Warn().Err(err).Msg("failed to close gzip writer")
-		}
-	}()
-	_, err = io.Copy(gzipWriter, inReader)
+	_, err = gzipWriter.Write(in)
 	if err != nil {
 		return nil, err
 	}
@@ -54,5 +65,6 @@ func Zip(in []byte, level int) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	gzipWriterPools[level-gzip.HuffmanOnly].Put(gzipWriter)
 	return result.Bytes(), nil
 }
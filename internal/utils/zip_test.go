@@ -1,7 +1,9 @@
 package utils_test
 
 import (
+	"bytes"
 	"compress/gzip"
+	"math/rand/v2"
 	"testing"
 
 	"github.com/ngergs/websrv/v3/internal/utils"
@@ -23,3 +25,54 @@ func TestUnzipBadInput(t *testing.T) {
 	_, err := utils.Unzip(testMsg)
 	require.Error(t, err)
 }
+
+func TestZipInvalidLevel(t *testing.T) {
+	_, err := utils.Zip([]byte("test123"), gzip.BestCompression+1)
+	require.Error(t, err)
+}
+
+func TestZipLevelSize(t *testing.T) {
+	// pseudo-random words are compressible but leave room for the levels to differ
+	words := []string{"alpha", "beta", "gamma", "delta", "epsilon", "zeta", "eta", "theta"}
+	random := rand.New(rand.NewPCG(1, 2))
+	var data bytes.Buffer
+	for range 20000 {
+		data.WriteString(words[random.IntN(len(words))])
+		data.WriteByte(' ')
+	}
+
+	fastest, err := utils.Zip(data.Bytes(), gzip.BestSpeed)
+	require.NoError(t, err)
+	smallest, err := utils.Zip(data.Bytes(), gzip.BestCompression)
+	require.NoError(t, err)
+	require.Less(t, len(smallest), len(fastest))
+
+	// pooled writers have to be reset to the level they have been created for
+	fastestAgain, err := utils.Zip(data.Bytes(), gzip.BestSpeed)
+	require.NoError(t, err)
+	require.Equal(t, fastest, fastestAgain)
+}
+
+var benchmarkData = bytes.Repeat([]byte("websrv benchmark data "), 1024)
+
+// BenchmarkZip uses the pooled gzip writers
+func BenchmarkZip(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := utils.Zip(benchmarkData, gzip.DefaultCompression)
+		require.NoError(b, err)
+	}
+}
+
+// BenchmarkZipUnpooled is the baseline that creates a new gzip writer for each call
+func BenchmarkZipUnpooled(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var result bytes.Buffer
+		gzipWriter, err := gzip.NewWriterLevel(&result, gzip.DefaultCompression)
+		require.NoError(b, err)
+		_, err = gzipWriter.Write(benchmarkData)
+		require.NoError(b, err)
+		require.NoError(b, gzipWriter.Close())
+	}
+}
@@ -10,19 +10,44 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var DomainLabel = "domain"
 var StatusLabel = "status"
+var FallbackLabel = "fallback"
+var ProtocolLabel = "protocol"
+var TemplateLabel = "template"
 
 // PrometheusRegistration wraps a prometheus registerer and corresponding registered types.
 type PrometheusRegistration struct {
-	bytesSend  *prometheus.CounterVec
-	statusCode *prometheus.CounterVec
+	bytesSend      *prometheus.CounterVec
+	statusCode     *prometheus.CounterVec
+	fileServes     *prometheus.CounterVec
+	duration       *prometheus.HistogramVec
+	protocol       *prometheus.CounterVec
+	templateErrors *prometheus.CounterVec
+	// tlsHandshakeFailures is used by the NewErrorLog, nil if not enabled
+	tlsHandshakeFailures *prometheus.CounterVec
+	// writeErrors is used by the WriteErrorHandler
+	writeErrors *prometheus.CounterVec
+}
+
+// AccessMetricsOptions holds the options for the optional access metrics.
+type AccessMetricsOptions struct {
+	// Protocol counts the requests per negotiated protocol like http/1.1, h2 or h3
+	Protocol bool
+	// TLS counts the failed TLS handshakes per coarse reason via the NewErrorLog, only useful for servers with a TLS listener
+	TLS bool
 }
 
 // AccessMetricsRegister registrates the relevant prometheus types and returns a custom registration type
 func AccessMetricsRegister(registerer prometheus.Registerer, prometheusNamespace string) (*PrometheusRegistration, error) {
+	return AccessMetricsRegisterWithOptions(registerer, prometheusNamespace, AccessMetricsOptions{})
+}
+
+// AccessMetricsRegisterWithOptions registrates the relevant prometheus types including the optional ones and returns a custom registration type
+func AccessMetricsRegisterWithOptions(registerer prometheus.Registerer, prometheusNamespace string, options AccessMetricsOptions) (*PrometheusRegistration, error) {
 	var bytesSend = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Namespace: prometheusNamespace,
 		Subsystem: "access",
@@ -35,6 +60,32 @@ func AccessMetricsRegister(registerer prometheus.Registerer, prometheusNamespace
 		Name:      "http_statuscode",
 		Help:      "HTTP Response status code.",
 	}, []string{DomainLabel, StatusLabel})
+	var fileServes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Subsystem: "access",
+		Name:      "file_serves",
+		Help:      "Number of successful responses served directly (fallback=false) or via the fallback file (fallback=true).",
+	}, []string{DomainLabel, FallbackLabel})
+	var duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: prometheusNamespace,
+		Subsystem: "access",
+		Name:      "request_duration_seconds",
+		Help:      "Time till the response has been written.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{DomainLabel})
+	var templateErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Subsystem: "template",
+		Name:      "errors",
+		Help:      "Number of failed template file renderings.",
+	}, []string{TemplateLabel})
+
+	var writeErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Subsystem: "access",
+		Name:      "write_errors",
+		Help:      "Number of responses whose body could not be written completely per reason, client_disconnect or error.",
+	}, []string{DomainLabel, ReasonLabel})
 
 	err := registerer.Register(bytesSend)
 	if err != nil {
@@ -44,31 +95,172 @@ func AccessMetricsRegister(registerer prometheus.Registerer, prometheusNamespace
 	if err != nil {
 		return nil, fmt.Errorf("failed to register http_statuscode metric: %w", err)
 	}
-	return &PrometheusRegistration{
-		bytesSend:  bytesSend,
-		statusCode: statusCode,
-	}, nil
+	err = registerer.Register(fileServes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register file_serves metric: %w", err)
+	}
+	err = registerer.Register(duration)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register request_duration_seconds metric: %w", err)
+	}
+	err = registerer.Register(templateErrors)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register template errors metric: %w", err)
+	}
+	err = registerer.Register(writeErrors)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register write errors metric: %w", err)
+	}
+	registration := &PrometheusRegistration{
+		bytesSend:      bytesSend,
+		statusCode:     statusCode,
+		fileServes:     fileServes,
+		duration:       duration,
+		templateErrors: templateErrors,
+		writeErrors:    writeErrors,
+	}
+	if options.Protocol {
+		registration.protocol = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: "access",
+			Name:      "protocol_requests",
+			Help:      "Number of requests per negotiated protocol.",
+		}, []string{DomainLabel, ProtocolLabel})
+		err = registerer.Register(registration.protocol)
+		if err != nil {
+			return nil, fmt.Errorf("failed to register protocol_requests metric: %w", err)
+		}
+	}
+	if options.TLS {
+		registration.tlsHandshakeFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: "tls",
+			Name:      "handshake_failures",
+			Help:      "Number of failed TLS handshakes per coarse reason.",
+		}, []string{ReasonLabel})
+		err = registerer.Register(registration.tlsHandshakeFailures)
+		if err != nil {
+			return nil, fmt.Errorf("failed to register tls handshake failures metric: %w", err)
+		}
+	}
+	return registration, nil
 }
 
 // AccessMetricsHandler collects the bytes send out as well as the status codes as prometheus metrics and writes them
 // to the  registry. The registerer has to be prepared via the AccessMetricsRegister function.
+// If a FallbackHandler is part of the following handler chain, it is also recorded for successful responses
+// whether the fallback file has been served.
 func AccessMetricsHandler(next http.Handler, registration *PrometheusRegistration) http.Handler {
+	return AccessMetricsRecorderHandler(next, registration)
+}
+
+// AccessMetricsRecorderHandler collects the access metrics of the responses and passes them to the recorder.
+// If a FallbackHandler is part of the following handler chain and the recorder implements the FallbackRecorder interface,
+// it is also recorded for successful responses, i.e. 2xx and HTTP 304, whether the fallback file has been served.
+func AccessMetricsRecorderHandler(next http.Handler, recorder MetricsRecorder) http.Handler {
+	fallbackRecorder, recordsFallback := recorder.(FallbackRecorder)
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, decision := withFallbackDecision(r)
 		m := httpsnoop.CaptureMetrics(next, w, r)
 
-		registration.statusCode.With(map[string]string{DomainLabel: r.Host, StatusLabel: strconv.Itoa(m.Code)}).Inc()
-		registration.bytesSend.With(map[string]string{DomainLabel: r.Host}).Add(float64(m.Written))
+		recorder.RecordStatus(r, m.Code)
+		recorder.RecordBytes(r, m.Written)
+		recorder.RecordDuration(r, m.Duration)
+		// errors are no file serves, neither directly nor via the fallback
+		if recordsFallback && decision.decided && ((m.Code >= http.StatusOK && m.Code < http.StatusMultipleChoices) || m.Code == http.StatusNotModified) {
+			fallbackRecorder.RecordFallback(r, decision.fallback)
+		}
 	})
 }
 
-// AccessLogHandler returns a http.Handler that adds access-logging on the info level.
+// AccessLogLevels maps the response status code classes to log levels.
+type AccessLogLevels struct {
+	// Success is the log level for 1xx and 2xx responses
+	Success zerolog.Level
+	// Redirect is the log level for 3xx responses
+	Redirect zerolog.Level
+	// ClientError is the log level for 4xx responses
+	ClientError zerolog.Level
+	// ServerError is the log level for 5xx responses
+	ServerError zerolog.Level
+}
+
+// DefaultAccessLogLevels logs client errors on the warn and server errors on the error level, all other responses on the info level.
+var DefaultAccessLogLevels = AccessLogLevels{
+	Success:     zerolog.InfoLevel,
+	Redirect:    zerolog.InfoLevel,
+	ClientError: zerolog.WarnLevel,
+	ServerError: zerolog.ErrorLevel,
+}
+
+// levelFor returns the log level for the given status code.
+func (levels *AccessLogLevels) levelFor(status int) zerolog.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return levels.ServerError
+	case status >= http.StatusBadRequest:
+		return levels.ClientError
+	case status >= http.StatusMultipleChoices:
+		return levels.Redirect
+	default:
+		return levels.Success
+	}
+}
+
+// AccessLogOptions holds the options for the access log.
+type AccessLogOptions struct {
+	// Levels determines the log level depending on the response status code
+	Levels AccessLogLevels
+	// CompressionRatio adds the uncompressed and compressed response sizes for compressed responses.
+	// The uncompressed size has to be reported via the CompressionStatsHandler or ReportUncompressedSize.
+	CompressionRatio bool
+	// Protocol adds the negotiated protocol like http/1.1, h2 or h3
+	Protocol bool
+	// ServedPath adds the filesystem path of the served file after rewrites like the fallback, reported via ReportServedPath.
+	// Reveals the filesystem structure, hence meant for debugging.
+	ServedPath bool
+	// LatencyPhases adds the latency until the response status is known, which covers the file lookup including the fallback,
+	// until the first body byte is written and in total. The first byte is omitted for responses without a body like HTTP 304 or HEAD.
+	LatencyPhases bool
+	// Logger receives the access log entries, e.g. from an AccessLogFile. Nil uses the global logger.
+	Logger *zerolog.Logger
+}
+
+// DefaultAccessLogOptions are the options used by the AccessLogHandler
+var DefaultAccessLogOptions = AccessLogOptions{
+	Levels: DefaultAccessLogLevels,
+}
+
+// AccessLogHandler returns a http.Handler that adds access-logging with the DefaultAccessLogOptions.
+func AccessLogHandler(next http.Handler) http.Handler {
+	return AccessLogHandlerWithOptions(next, DefaultAccessLogOptions)
+}
+
+// AccessLogHandlerWithOptions returns a http.Handler that adds access-logging. The log level is chosen after the response status is known.
 //
 //nolint:zerologlint // linter does not understand that we dispatch logEvent later on
-func AccessLogHandler(next http.Handler) http.Handler {
+func AccessLogHandlerWithOptions(next http.Handler, options AccessLogOptions) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		m := httpsnoop.CaptureMetrics(next, w, r)
+		var stats *compressionStats
+		if options.CompressionRatio {
+			r, stats = withCompressionStats(r)
+		}
+		var served *servedPath
+		if options.ServedPath {
+			r, served = withServedPath(r)
+		}
+		var phases *latencyPhases
+		wrappedW := w
+		if options.LatencyPhases {
+			wrappedW, phases = withLatencyPhases(w)
+		}
+		m := httpsnoop.CaptureMetrics(next, wrappedW, r)
 
-		logEvent := log.Info()
+		logger := options.Logger
+		if logger == nil {
+			logger = &log.Logger
+		}
+		logEvent := logger.WithLevel(options.Levels.levelFor(m.Code))
 		requestId := r.Context().Value(middleware.RequestIDKey)
 		if requestId != nil {
 			if requestIdStr, ok := requestId.(string); ok {
@@ -77,7 +269,30 @@ func AccessLogHandler(next http.Handler) http.Handler {
 				log.Warn().Msgf("Request id is not, but not a string value: %v", requestId)
 			}
 		}
-		logEvent.Dict("httpRequest", zerolog.Dict().
+		if stats != nil && stats.reported && stats.uncompressed > 0 && m.Written > 0 && w.Header().Get("Content-Encoding") != "" {
+			logEvent = logEvent.Dict("compression", zerolog.Dict().
+				Int64("uncompressedSize", stats.uncompressed).
+				Int64("compressedSize", m.Written).
+				Float64("ratio", float64(stats.uncompressed)/float64(m.Written)))
+		}
+		if served != nil && served.reported {
+			logEvent = logEvent.Str("servedPath", served.path)
+		}
+		if phases != nil {
+			phasesDict := zerolog.Dict()
+			if !phases.status.IsZero() {
+				phasesDict = phasesDict.Str("resolve", formatLatency(phases.status.Sub(phases.start)))
+			}
+			if !phases.firstByte.IsZero() {
+				phasesDict = phasesDict.Str("firstByte", formatLatency(phases.firstByte.Sub(phases.start)))
+			}
+			logEvent = logEvent.Dict("latencyPhases", phasesDict.Str("total", formatLatency(time.Since(phases.start))))
+		}
+		httpRequest := zerolog.Dict()
+		if options.Protocol {
+			httpRequest = httpRequest.Str("protocol", negotiatedProtocol(r))
+		}
+		logEvent.Dict("httpRequest", httpRequest.
 			Str("requestMethod", r.Method).
 			Str("requestUrl", getFullUrl(r)).
 			Int("status", m.Code).
@@ -85,11 +300,35 @@ func AccessLogHandler(next http.Handler) http.Handler {
 			Str("userAgent", r.UserAgent()).
 			Str("remoteIp", r.RemoteAddr).
 			Str("referer", r.Referer()).
-			Str("latency", fmt.Sprintf("%.09fs", m.Duration.Seconds()))).
+			Str("latency", formatLatency(m.Duration))).
 			Msg("")
 	})
 }
 
+// formatLatency formats the duration in seconds with nanosecond precision like 0.000123456s
+func formatLatency(d time.Duration) string {
+	return fmt.Sprintf("%.09fs", d.Seconds())
+}
+
+// negotiatedProtocol returns the protocol of the request in the ALPN notation, e.g. http/1.1, h2 or h3.
+// Unencrypted HTTP/2 is reported as h2c.
+func negotiatedProtocol(r *http.Request) string {
+	if r.TLS != nil && r.TLS.NegotiatedProtocol != "" {
+		return r.TLS.NegotiatedProtocol
+	}
+	switch r.ProtoMajor {
+	case 3:
+		return "h3"
+	case 2:
+		if r.TLS == nil {
+			return "h2c"
+		}
+		return "h2"
+	default:
+		return fmt.Sprintf("http/%d.%d", r.ProtoMajor, r.ProtoMinor)
+	}
+}
+
 func getFullUrl(r *http.Request) string {
 	var sb strings.Builder
 	if r.TLS == nil {
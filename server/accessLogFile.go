@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"time"
+)
+
+// AccessLogFileOptions configures the rotation of an AccessLogFile.
+type AccessLogFileOptions struct {
+	// Path of the log file, rotated files are stored in the same directory
+	Path string
+	// MaxSizeMB is the size in megabytes after which the file is rotated, 0 defaults to 100 megabytes
+	MaxSizeMB int
+	// MaxAgeDays is the number of days to retain rotated files, 0 retains them regardless of their age
+	MaxAgeDays int
+	// MaxBackups is the number of rotated files to retain, 0 retains all of them
+	MaxBackups int
+	// Compress gzips the rotated files
+	Compress bool
+	// RotateInterval rotates the file additionally after the given interval, 0 disables time based rotation
+	RotateInterval time.Duration
+}
+
+// AccessLogFile is a size and time based rotating log file meant as nginx-style access.log.
+type AccessLogFile struct {
+	file           *lumberjack.Logger
+	rotateInterval time.Duration
+}
+
+// NewAccessLogFile returns an AccessLogFile. The file is only created with the first write.
+func NewAccessLogFile(options AccessLogFileOptions) *AccessLogFile {
+	return &AccessLogFile{
+		file: &lumberjack.Logger{
+			Filename:   options.Path,
+			MaxSize:    options.MaxSizeMB,
+			MaxAge:     options.MaxAgeDays,
+			MaxBackups: options.MaxBackups,
+			LocalTime:  false,
+			Compress:   options.Compress,
+		},
+		rotateInterval: options.RotateInterval,
+	}
+}
+
+// Logger returns a JSON logger that writes to the file.
+func (file *AccessLogFile) Logger() *zerolog.Logger {
+	logger := zerolog.New(file.file).With().Timestamp().Logger()
+	return &logger
+}
+
+// RotatePeriodically rotates the file after each RotateInterval until the context is done. Blocks until then.
+func (file *AccessLogFile) RotatePeriodically(ctx context.Context) {
+	if file.rotateInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(file.rotateInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := file.file.Rotate(); err != nil {
+				log.Error().Err(err).Msgf("Error rotating access log file %s", file.file.Filename)
+			}
+		}
+	}
+}
+
+// Close closes the file. Writes afterward reopen it.
+func (file *AccessLogFile) Close() error {
+	return file.file.Close()
+}
@@ -0,0 +1,49 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAccessLogFile(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "access.log")
+	file := server.NewAccessLogFile(server.AccessLogFileOptions{Path: logPath})
+	options := server.DefaultAccessLogOptions
+	options.Logger = file.Logger()
+	handler := server.AccessLogHandlerWithOptions(getStaticHandler(dummyResponse), options)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, file.Close())
+
+	data, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	var logEntry map[string]any
+	require.NoError(t, json.Unmarshal(data, &logEntry))
+	require.Equal(t, "info", logEntry["level"])
+	require.Contains(t, logEntry, "httpRequest")
+}
+
+func TestAccessLogFileRotation(t *testing.T) {
+	logDir := t.TempDir()
+	file := server.NewAccessLogFile(server.AccessLogFileOptions{Path: filepath.Join(logDir, "access.log"), RotateInterval: 10 * time.Millisecond})
+	logger := file.Logger()
+	logger.Info().Msg("before rotation")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go file.RotatePeriodically(ctx)
+
+	require.Eventually(t, func() bool {
+		entries, err := os.ReadDir(logDir)
+		require.NoError(t, err)
+		return len(entries) > 1
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	require.NoError(t, file.Close())
+}
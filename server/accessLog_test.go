@@ -0,0 +1,304 @@
+package server_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+const metricsNamespace = "test"
+
+func TestFallbackMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registration, err := server.AccessMetricsRegister(registry, metricsNamespace)
+	require.NoError(t, err)
+	_, r, next := getDefaultHandlerMocks()
+	next.serveHttpFunc = func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != fallbackPath && r.URL.Path != "/main.js" {
+			w.WriteHeader(fallbackStatus)
+		}
+	}
+	handler := server.AccessMetricsHandler(server.FallbackHandler(next, fallbackPath, fallbackStatus), registration)
+	for _, requestPath := range []string{"/main.js", "/route", "/other-route"} {
+		w, _, _ := getDefaultHandlerMocks()
+		r.URL = &url.URL{Path: requestPath}
+		handler.ServeHTTP(w, r)
+	}
+
+	require.InDelta(t, 1, getCounterValue(t, registry, metricsNamespace+"_access_file_serves", map[string]string{server.FallbackLabel: "false"}), 0)
+	require.InDelta(t, 2, getCounterValue(t, registry, metricsNamespace+"_access_file_serves", map[string]string{server.FallbackLabel: "true"}), 0)
+}
+
+func TestFallbackMetricsErrors(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registration, err := server.AccessMetricsRegister(registry, metricsNamespace)
+	require.NoError(t, err)
+	_, r, next := getDefaultHandlerMocks()
+	next.serveHttpFunc = func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/main.js":
+		case "/broken.js":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(fallbackStatus)
+		}
+	}
+	handler := server.AccessMetricsHandler(server.FallbackHandlerWithOptions(next, fallbackPath, server.FallbackOptions{SkipFiles: true}, fallbackStatus), registration)
+	for _, requestPath := range []string{"/main.js", "/missing.js", "/broken.js", "/route"} {
+		w, _, _ := getDefaultHandlerMocks()
+		r.URL = &url.URL{Path: requestPath}
+		handler.ServeHTTP(w, r)
+	}
+
+	// neither the HTTP 404 for the skipped file nor the server error count as direct serve, the failed fallback neither counts
+	require.InDelta(t, 1, getCounterValue(t, registry, metricsNamespace+"_access_file_serves", map[string]string{server.FallbackLabel: "false"}), 0)
+	require.InDelta(t, 0, getCounterValue(t, registry, metricsNamespace+"_access_file_serves", map[string]string{server.FallbackLabel: "true"}), 0)
+}
+
+func TestAccessLogLevels(t *testing.T) {
+	for status, level := range map[int]string{
+		http.StatusOK:                  "info",
+		http.StatusNotModified:         "info",
+		http.StatusNotFound:            "warn",
+		http.StatusInternalServerError: "error",
+	} {
+		logEntry := getAccessLogEntry(t, server.DefaultAccessLogOptions, status)
+		require.Equal(t, level, logEntry["level"])
+	}
+}
+
+func TestAccessLogLevelsOverride(t *testing.T) {
+	options := server.DefaultAccessLogOptions
+	options.Levels.Success = zerolog.DebugLevel
+	logEntry := getAccessLogEntry(t, options, http.StatusOK)
+	require.Equal(t, "debug", logEntry["level"])
+}
+
+func TestAccessLogCompressionRatio(t *testing.T) {
+	options := server.DefaultAccessLogOptions
+	options.CompressionRatio = true
+	body := strings.Repeat("compressible ", 100)
+	logEntry := getAccessLogEntryFor(t, options, getGzipRequest(), getCompressedHandler(body))
+	compression, ok := logEntry["compression"].(map[string]any)
+	require.True(t, ok)
+	require.InDelta(t, len(body), compression["uncompressedSize"], 0)
+	compressedSize, ok := compression["compressedSize"].(float64)
+	require.True(t, ok)
+	require.Less(t, compressedSize, float64(len(body)))
+	require.InDelta(t, float64(len(body))/compressedSize, compression["ratio"], 1e-9)
+}
+
+func TestAccessLogCompressionRatioUncompressed(t *testing.T) {
+	options := server.DefaultAccessLogOptions
+	options.CompressionRatio = true
+	logEntry := getAccessLogEntryFor(t, options, httptest.NewRequest(http.MethodGet, "/", nil), getCompressedHandler(dummyResponse))
+	require.NotContains(t, logEntry, "compression")
+}
+
+func TestAccessLogCompressionRatioDisabled(t *testing.T) {
+	logEntry := getAccessLogEntryFor(t, server.DefaultAccessLogOptions, getGzipRequest(), getCompressedHandler(strings.Repeat("compressible ", 100)))
+	require.NotContains(t, logEntry, "compression")
+}
+
+// getGzipRequest returns a request that accepts gzip encoded responses
+func getGzipRequest() *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	return r
+}
+
+// getCompressedHandler returns a gzip compressing handler that reports the uncompressed size of the plain text body
+func getCompressedHandler(body string) http.Handler {
+	return middleware.Compress(gzip.DefaultCompression, "text/plain")(server.CompressionStatsHandler(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte(body))
+		})))
+}
+
+func TestAccessLogProtocol(t *testing.T) {
+	options := server.DefaultAccessLogOptions
+	options.Protocol = true
+	logEntry := getAccessLogEntryFor(t, options, httptest.NewRequest(http.MethodGet, "/", nil), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	require.Equal(t, "http/1.1", logEntry["httpRequest"].(map[string]any)["protocol"])
+
+	logEntry = getAccessLogEntryFor(t, options, getH2Request(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	require.Equal(t, "h2", logEntry["httpRequest"].(map[string]any)["protocol"])
+
+	logEntry = getAccessLogEntryFor(t, server.DefaultAccessLogOptions, getH2Request(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	require.NotContains(t, logEntry["httpRequest"], "protocol")
+}
+
+func TestAccessLogServedPath(t *testing.T) {
+	fsys := fstest.MapFS{"index.html": {Data: []byte(dummyResponse)}}
+	handler := server.FallbackHandler(server.FileServer(fsys), "/index.html", http.StatusNotFound)
+	options := server.DefaultAccessLogOptions
+	options.ServedPath = true
+	logEntry := getAccessLogEntryFor(t, options, httptest.NewRequest(http.MethodGet, "/route", nil), handler)
+	require.Equal(t, "/index.html", logEntry["servedPath"])
+
+	logEntry = getAccessLogEntryFor(t, server.DefaultAccessLogOptions, httptest.NewRequest(http.MethodGet, "/route", nil), handler)
+	require.NotContains(t, logEntry, "servedPath")
+}
+
+func TestAccessLogLatencyPhases(t *testing.T) {
+	options := server.DefaultAccessLogOptions
+	options.LatencyPhases = true
+	logEntry := getAccessLogEntryFor(t, options, httptest.NewRequest(http.MethodGet, "/", nil), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Duration(10) * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		time.Sleep(time.Duration(10) * time.Millisecond)
+		_, _ = w.Write([]byte(dummyResponse))
+	}))
+	phases := getLatencyPhases(t, logEntry)
+	require.GreaterOrEqual(t, phases["resolve"], 0.01)
+	require.GreaterOrEqual(t, phases["firstByte"], phases["resolve"]+0.01)
+	require.GreaterOrEqual(t, phases["total"], phases["firstByte"])
+
+	// no body for HTTP 304
+	logEntry = getAccessLogEntryFor(t, options, httptest.NewRequest(http.MethodGet, "/", nil), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	phases = getLatencyPhases(t, logEntry)
+	require.Contains(t, phases, "resolve")
+	require.NotContains(t, phases, "firstByte")
+	require.Contains(t, phases, "total")
+
+	logEntry = getAccessLogEntryFor(t, server.DefaultAccessLogOptions, httptest.NewRequest(http.MethodGet, "/", nil), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	require.NotContains(t, logEntry, "latencyPhases")
+}
+
+// getLatencyPhases returns the latency phases of the access log entry in seconds
+func getLatencyPhases(t *testing.T, logEntry map[string]any) map[string]float64 {
+	rawPhases, ok := logEntry["latencyPhases"].(map[string]any)
+	require.True(t, ok)
+	phases := make(map[string]float64, len(rawPhases))
+	for name, rawPhase := range rawPhases {
+		phase, err := time.ParseDuration(rawPhase.(string))
+		require.NoError(t, err)
+		phases[name] = phase.Seconds()
+	}
+	return phases
+}
+
+func TestProtocolMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registration, err := server.AccessMetricsRegisterWithOptions(registry, metricsNamespace, server.AccessMetricsOptions{Protocol: true})
+	require.NoError(t, err)
+	handler := server.AccessMetricsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), registration)
+	for _, r := range []*http.Request{httptest.NewRequest(http.MethodGet, "/", nil), getH2Request(), getH2Request()} {
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	require.InDelta(t, 1, getCounterValue(t, registry, metricsNamespace+"_access_protocol_requests", map[string]string{server.ProtocolLabel: "http/1.1"}), 0)
+	require.InDelta(t, 2, getCounterValue(t, registry, metricsNamespace+"_access_protocol_requests", map[string]string{server.ProtocolLabel: "h2"}), 0)
+}
+
+// getH2Request returns a request that simulates HTTP/2 negotiated via TLS ALPN
+func getH2Request() *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+	r.Proto = "HTTP/2.0"
+	r.ProtoMajor = 2
+	r.ProtoMinor = 0
+	r.TLS.NegotiatedProtocol = "h2"
+	return r
+}
+
+// getAccessLogEntry returns the parsed access log entry for a response with the given status
+func getAccessLogEntry(t *testing.T, options server.AccessLogOptions, status int) map[string]any {
+	return getAccessLogEntryFor(t, options, httptest.NewRequest(http.MethodGet, "/", nil), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+}
+
+// getAccessLogEntryFor returns the parsed access log entry for the response of the next handler
+func getAccessLogEntryFor(t *testing.T, options server.AccessLogOptions, r *http.Request, next http.Handler) map[string]any {
+	var logOutput bytes.Buffer
+	originalLogger := log.Logger
+	log.Logger = zerolog.New(&logOutput)
+	defer func() {
+		log.Logger = originalLogger
+	}()
+
+	handler := server.AccessLogHandlerWithOptions(next, options)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	var logEntry map[string]any
+	require.NoError(t, json.Unmarshal(logOutput.Bytes(), &logEntry))
+	return logEntry
+}
+
+// getCounterValue returns the value of the counter with the given name whose labels contain the given labels.
+func getCounterValue(t *testing.T, registry *prometheus.Registry, name string, labels map[string]string) float64 {
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metricLoop:
+		for _, metric := range family.GetMetric() {
+			for k, v := range labels {
+				found := false
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == k && label.GetValue() == v {
+						found = true
+					}
+				}
+				if !found {
+					continue metricLoop
+				}
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+// dummyRecorder is a server.MetricsRecorder that stores the recorded values
+type dummyRecorder struct {
+	status   int
+	bytes    int64
+	duration time.Duration
+}
+
+func (recorder *dummyRecorder) RecordStatus(_ *http.Request, status int) {
+	recorder.status = status
+}
+
+func (recorder *dummyRecorder) RecordBytes(_ *http.Request, bytes int64) {
+	recorder.bytes = bytes
+}
+
+func (recorder *dummyRecorder) RecordDuration(_ *http.Request, duration time.Duration) {
+	recorder.duration = duration
+}
+
+func TestAccessMetricsRecorder(t *testing.T) {
+	recorder := &dummyRecorder{}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(dummyResponse))
+	})
+	// the dummyRecorder does not implement the server.FallbackRecorder interface
+	handler := server.AccessMetricsRecorderHandler(server.FallbackHandler(next, fallbackPath, fallbackStatus), recorder)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.Equal(t, http.StatusAccepted, recorder.status)
+	require.Equal(t, int64(len(dummyResponse)), recorder.bytes)
+	require.GreaterOrEqual(t, recorder.duration, time.Millisecond)
+}
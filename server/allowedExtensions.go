@@ -0,0 +1,52 @@
+package server
+
+import (
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// AllowedExtensionsHandler rejects requests whose last path segment has a file extension that is not in the allowed extensions
+// with HTTP 404, e.g. to avoid exposing source maps or backup files. The extensions include the leading dot like ".js"
+// and are compared case-insensitively. Dotfiles like /.env count as extension. Paths without extension are only passed on
+// if they are no regular file in the filesystem, as they are directories or routes for the fallback then.
+func AllowedExtensionsHandler(next http.Handler, fsys fs.FS, extensions []string) http.Handler {
+	allowed := extensionSet(extensions)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rejectsExtension(allowed, r.URL.Path, func() bool { return isRegularFile(fsys, r.URL.Path) }) {
+			Error(w, r, "", http.StatusNotFound)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// extensionSet returns the lowercase extensions as set
+func extensionSet(extensions []string) map[string]bool {
+	allowed := make(map[string]bool, len(extensions))
+	for _, extension := range extensions {
+		allowed[strings.ToLower(extension)] = true
+	}
+	return allowed
+}
+
+// rejectsExtension returns whether the extension of the url path is not allowed.
+// Paths without extension are rejected if isRegularFile reports them as regular file.
+func rejectsExtension(allowed map[string]bool, urlPath string, isRegularFile func() bool) bool {
+	extension := path.Ext(urlPath)
+	if len(extension) > 1 {
+		return !allowed[strings.ToLower(extension)]
+	}
+	return isRegularFile()
+}
+
+// isRegularFile returns whether the url path is a regular file in the filesystem
+func isRegularFile(fsys fs.FS, urlPath string) bool {
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	if name == "" {
+		return false
+	}
+	info, err := fs.Stat(fsys, name)
+	return err == nil && info.Mode().IsRegular()
+}
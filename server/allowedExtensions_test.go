@@ -0,0 +1,54 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowedExtensions(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":      {Data: []byte(fallbackResponse)},
+		"main.js":         {Data: []byte(dummyResponse)},
+		"main.js.map":     {Data: []byte(dummyResponse)},
+		"LOGO.PNG":        {Data: []byte(dummyResponse)},
+		".env":            {Data: []byte(dummyResponse)},
+		"v1.2/LICENSE":    {Data: []byte(dummyResponse)},
+		"backup/main.bak": {Data: []byte(dummyResponse)},
+	}
+	handler := server.AllowedExtensionsHandler(
+		server.FallbackHandler(server.FileServer(fsys), "/", http.StatusNotFound),
+		fsys, []string{".html", ".js", ".png"})
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{path: "/main.js", status: http.StatusOK, body: dummyResponse},
+		{path: "/LOGO.PNG", status: http.StatusOK, body: dummyResponse},
+		{path: "/main.js.map", status: http.StatusNotFound},
+		// files without extension are rejected, directories are not
+		{path: "/v1.2/LICENSE", status: http.StatusNotFound},
+		{path: "/backup/", status: http.StatusOK},
+		{path: "/.env", status: http.StatusNotFound},
+		{path: "/backup/main.bak", status: http.StatusNotFound},
+		// rejected extensions are not served via the fallback
+		{path: "/missing.map", status: http.StatusNotFound},
+		{path: "/route", status: http.StatusOK, body: fallbackResponse},
+		{path: "/missing.js", status: http.StatusOK, body: fallbackResponse},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+			require.Equal(t, test.status, w.Code)
+			if test.body != "" {
+				require.Equal(t, test.body, w.Body.String())
+			}
+		})
+	}
+}
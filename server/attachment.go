@@ -0,0 +1,52 @@
+package server
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/ngergs/websrv/v3/internal/utils"
+)
+
+// AttachmentOptions determines the responses that are served as download.
+type AttachmentOptions struct {
+	// MediaTypes are the media types without parameters like application/pdf
+	MediaTypes []string
+	// Paths are regular expressions for the request paths
+	Paths []*regexp.Regexp
+}
+
+// AttachmentHandler sets the Content-Disposition HTTP-Header of successful responses to attachment if the media type or
+// the request path match the options, so that browsers download the file instead of rendering it.
+// The filename is the last segment of the request path, non-ASCII names are encoded according to RFC 5987.
+// Responses served via a FallbackHandler further up the handler chain are not affected.
+func AttachmentHandler(next http.Handler, options AttachmentOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pathMatches := false
+		for _, pathRegex := range options.Paths {
+			if pathRegex.MatchString(r.URL.Path) {
+				pathMatches = true
+				break
+			}
+		}
+		next.ServeHTTP(wrapWriteHeader(w, func(code int) {
+			if code < http.StatusOK || code >= http.StatusMultipleChoices || isFallback(r) {
+				return
+			}
+			mediaType, _, _ := strings.Cut(w.Header().Get("Content-Type"), ";")
+			if !pathMatches && !utils.Contains(options.MediaTypes, strings.TrimSpace(mediaType)) {
+				return
+			}
+			disposition := "attachment"
+			if filename := path.Base(r.URL.Path); filename != "/" && filename != "." {
+				// empty if the filename can not be encoded
+				if withFilename := mime.FormatMediaType(disposition, map[string]string{"filename": filename}); withFilename != "" {
+					disposition = withFilename
+				}
+			}
+			w.Header().Set("Content-Disposition", disposition)
+		}), r)
+	})
+}
@@ -0,0 +1,40 @@
+package server_test
+
+import (
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestAttachment(t *testing.T) {
+	options := server.AttachmentOptions{
+		MediaTypes: []string{"application/pdf"},
+		Paths:      []*regexp.Regexp{regexp.MustCompile("^/downloads/")},
+	}
+	for _, tc := range []struct {
+		target      string
+		contentType string
+		status      int
+		disposition string
+	}{
+		{target: "/report.pdf", contentType: "application/pdf", status: http.StatusOK, disposition: `attachment; filename=report.pdf`},
+		{target: "/downloads/notes.txt", contentType: "text/plain; charset=utf-8", status: http.StatusOK, disposition: `attachment; filename=notes.txt`},
+		{target: "/downloads/my%20notes.txt", contentType: "text/plain", status: http.StatusOK, disposition: `attachment; filename="my notes.txt"`},
+		{target: "/downloads/%C3%BCbersicht.pdf", contentType: "application/pdf", status: http.StatusOK, disposition: `attachment; filename*=utf-8''%C3%BCbersicht.pdf`},
+		{target: "/index.html", contentType: "text/html", status: http.StatusOK, disposition: ""},
+		{target: "/report.pdf", contentType: "application/pdf", status: http.StatusNotModified, disposition: ""},
+	} {
+		t.Run(tc.target, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				w.WriteHeader(tc.status)
+			})
+			w := httptest.NewRecorder()
+			server.AttachmentHandler(next, options).ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.target, nil))
+			require.Equal(t, tc.disposition, w.Header().Get("Content-Disposition"))
+		})
+	}
+}
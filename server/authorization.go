@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+)
+
+// Authorizer decides whether a request is allowed to be served, e.g. by validating a JWT or checking an IP allowlist.
+type Authorizer interface {
+	// Authorize returns whether the request is allowed. The status is used for the response to denied requests,
+	// it should be either HTTP 401 or 403. A status of 0 defaults to HTTP 403.
+	Authorize(r *http.Request) (allowed bool, status int)
+}
+
+// AuthorizerFunc is an adapter to use an ordinary function as Authorizer.
+type AuthorizerFunc func(r *http.Request) (allowed bool, status int)
+
+// Authorize calls the underlying function.
+func (f AuthorizerFunc) Authorize(r *http.Request) (allowed bool, status int) {
+	return f(r)
+}
+
+// AllowAll is an Authorizer that allows all requests.
+var AllowAll = AuthorizerFunc(func(_ *http.Request) (bool, int) {
+	return true, 0
+})
+
+// AuthorizationHandler asks the authorizer before serving the request. Denied requests are answered with the status
+// from the authorizer and the denyBody. A nil authorizer allows all requests.
+func AuthorizationHandler(next http.Handler, authorizer Authorizer, denyBody string) http.Handler {
+	if authorizer == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, status := authorizer.Authorize(r)
+		if allowed {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if status == 0 {
+			status = http.StatusForbidden
+		}
+		Error(w, r, denyBody, status)
+	})
+}
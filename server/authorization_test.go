@@ -0,0 +1,48 @@
+package server_test
+
+import (
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const denyBody = "denied"
+
+func TestAuthorizationAllowed(t *testing.T) {
+	result := getAuthorizationResponse(t, server.AllowAll)
+	require.Equal(t, http.StatusOK, result.StatusCode)
+}
+
+func TestAuthorizationNil(t *testing.T) {
+	result := getAuthorizationResponse(t, nil)
+	require.Equal(t, http.StatusOK, result.StatusCode)
+}
+
+func TestAuthorizationDenied(t *testing.T) {
+	result := getAuthorizationResponse(t, server.AuthorizerFunc(func(_ *http.Request) (bool, int) {
+		return false, http.StatusUnauthorized
+	}))
+	require.Equal(t, http.StatusUnauthorized, result.StatusCode)
+	require.Equal(t, denyBody, strings.TrimSpace(string(getReceivedData(t, result.Body))))
+}
+
+func TestAuthorizationDeniedDefaultStatus(t *testing.T) {
+	result := getAuthorizationResponse(t, server.AuthorizerFunc(func(_ *http.Request) (bool, int) {
+		return false, 0
+	}))
+	require.Equal(t, http.StatusForbidden, result.StatusCode)
+}
+
+func getAuthorizationResponse(t *testing.T, authorizer server.Authorizer) *http.Response {
+	w, r, next := getDefaultHandlerMocks()
+	handler := server.AuthorizationHandler(next, authorizer, denyBody)
+	handler.ServeHTTP(w, r)
+	result := w.Result()
+	t.Cleanup(func() {
+		err := result.Body.Close()
+		require.NoError(t, err)
+	})
+	return result
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"html"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var (
+	baseHrefRegex = regexp.MustCompile(`(?i)(<base\s[^>]*?href\s*=\s*)("[^"]*"|'[^']*'|[^\s>]+)`)
+	headTagRegex  = regexp.MustCompile(`(?i)<head(\s[^>]*)?>`)
+)
+
+// BaseHrefHandler rewrites the href of the <base> HTML-Element in all HTML responses to the given basePath.
+// If no <base> element is present, it is injected directly after the <head> element.
+// The handler is a no-op if the basePath is empty or the root path.
+func BaseHrefHandler(next http.Handler, basePath string) http.Handler {
+	if basePath == "" || basePath == "/" {
+		return next
+	}
+	if !strings.HasSuffix(basePath, "/") {
+		// relative URLs are resolved against the last directory of the base href
+		basePath += "/"
+	}
+	href := `"` + html.EscapeString(basePath) + `"`
+	return transformHandler(next, isHtml, func(_ http.Header, body []byte) []byte {
+		if baseHrefRegex.Match(body) {
+			return baseHrefRegex.ReplaceAllFunc(body, func(match []byte) []byte {
+				attributePrefix := baseHrefRegex.FindSubmatch(match)[1]
+				return append(append([]byte{}, attributePrefix...), href...)
+			})
+		}
+		loc := headTagRegex.FindIndex(body)
+		if loc == nil {
+			return body
+		}
+		result := make([]byte, 0, len(body)+len(href)+len("<base href=>"))
+		result = append(result, body[:loc[1]]...)
+		result = append(result, "<base href="+href+">"...)
+		return append(result, body[loc[1]:]...)
+	})
+}
+
+// isHtml checks whether the Content-Type HTTP-Header of the response is set to text/html.
+func isHtml(_ int, header http.Header) bool {
+	return strings.HasPrefix(header.Get("Content-Type"), "text/html")
+}
@@ -0,0 +1,55 @@
+package server_test
+
+import (
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func TestBaseHrefRewrite(t *testing.T) {
+	body := getBaseHrefResponse(t, "text/html; charset=utf-8", `<html><head><base href="/"></head></html>`, "/app")
+	require.Equal(t, `<html><head><base href="/app/"></head></html>`, body)
+}
+
+func TestBaseHrefInject(t *testing.T) {
+	body := getBaseHrefResponse(t, "text/html; charset=utf-8", `<html><head lang="en"><title>a</title></head></html>`, "/app/")
+	require.Equal(t, `<html><head lang="en"><base href="/app/"><title>a</title></head></html>`, body)
+}
+
+func TestBaseHrefNonHtml(t *testing.T) {
+	original := `<head><base href="/"></head>`
+	body := getBaseHrefResponse(t, "text/plain", original, "/app/")
+	require.Equal(t, original, body)
+}
+
+func TestBaseHrefRoot(t *testing.T) {
+	original := `<head><base href="/test/"></head>`
+	body := getBaseHrefResponse(t, "text/html", original, "/")
+	require.Equal(t, original, body)
+}
+
+func getBaseHrefResponse(t *testing.T, contentType string, response string, basePath string) string {
+	w, r, next := getDefaultHandlerMocks()
+	next.serveHttpFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(response)))
+		_, err := w.Write([]byte(response))
+		require.NoError(t, err)
+	}
+	r.Method = http.MethodGet
+	r.URL = &url.URL{Path: "/"}
+	handler := server.BaseHrefHandler(next, basePath)
+	handler.ServeHTTP(w, r)
+	result := w.Result()
+	defer func() {
+		err := result.Body.Close()
+		require.NoError(t, err)
+	}()
+	require.Equal(t, http.StatusOK, result.StatusCode)
+	data := getReceivedData(t, result.Body)
+	require.Equal(t, strconv.Itoa(len(data)), result.Header.Get("Content-Length"))
+	return string(data)
+}
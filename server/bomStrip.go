@@ -0,0 +1,29 @@
+package server
+
+import (
+	"bytes"
+	"github.com/ngergs/websrv/v3/internal/utils"
+	"net/http"
+	"strings"
+)
+
+// utf8Bom is the byte order mark for UTF-8 encoded content
+var utf8Bom = []byte{0xEF, 0xBB, 0xBF}
+
+// BomStripHandler strips a leading UTF-8 byte order mark from responses whose media type is part of the given mediaTypes.
+func BomStripHandler(next http.Handler, mediaTypes []string) http.Handler {
+	return transformHandler(next, func(_ int, header http.Header) bool {
+		return utils.Contains(mediaTypes, getMediaType(header))
+	}, func(_ http.Header, body []byte) []byte {
+		return bytes.TrimPrefix(body, utf8Bom)
+	})
+}
+
+// getMediaType returns the media type from the Content-Type HTTP-Header without parameters like the charset.
+func getMediaType(header http.Header) string {
+	mediaType := header.Get("Content-Type")
+	if i := strings.Index(mediaType, ";"); i >= 0 {
+		mediaType = mediaType[0:i]
+	}
+	return strings.TrimSpace(mediaType)
+}
@@ -0,0 +1,46 @@
+package server_test
+
+import (
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"testing/fstest"
+)
+
+const bomContent = `{"a":1}`
+
+var bomFs = fstest.MapFS{
+	"data.json": {Data: append([]byte{0xEF, 0xBB, 0xBF}, bomContent...)},
+	"data.txt":  {Data: append([]byte{0xEF, 0xBB, 0xBF}, bomContent...)},
+}
+
+func TestBomStrip(t *testing.T) {
+	result := getBomStripResponse(t, "/data.json")
+	data := getReceivedData(t, result.Body)
+	require.Equal(t, bomContent, string(data))
+	require.Equal(t, strconv.Itoa(len(bomContent)), result.Header.Get("Content-Length"))
+}
+
+func TestBomStripOtherMediaType(t *testing.T) {
+	result := getBomStripResponse(t, "/data.txt")
+	data := getReceivedData(t, result.Body)
+	require.Equal(t, bomFs["data.txt"].Data, data)
+	require.Equal(t, strconv.Itoa(len(bomFs["data.txt"].Data)), result.Header.Get("Content-Length"))
+}
+
+func getBomStripResponse(t *testing.T, requestPath string) *http.Response {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, requestPath, nil)
+	handler := server.BomStripHandler(http.FileServer(http.FS(bomFs)), []string{"application/json"})
+	handler.ServeHTTP(w, r)
+	result := w.Result()
+	t.Cleanup(func() {
+		err := result.Body.Close()
+		require.NoError(t, err)
+	})
+	require.Equal(t, http.StatusOK, result.StatusCode)
+	return result
+}
@@ -4,95 +4,298 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/base64"
-	"github.com/felixge/httpsnoop"
-	"github.com/ngergs/websrv/v3/internal/utils"
-	"github.com/puzpuzpuz/xsync"
-	"github.com/rs/zerolog/log"
-	"io"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/cespare/xxhash/v2"
+	"golang.org/x/sync/singleflight"
+	"hash/crc32"
+	"io/fs"
 	"net/http"
+	"path"
+	"slices"
+	"strings"
 )
 
+var ErrUnknownHashAlgorithm = errors.New("unknown hash algorithm, only sha256, xxhash and crc32 are valid")
+
+// DefaultCacheMaxEntries is the default maximum number of ETags that are stored
+const DefaultCacheMaxEntries = 10000
+
+// HashFunc computes the base64 encoded hash of the given data.
+type HashFunc func(data []byte) string
+
+// Sha256Hash computes the base64 encoded SHA-256 hash. Slowest option, but collision resistant.
+func Sha256Hash(data []byte) string {
+	hash := sha256.Sum256(data)
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// XxHash computes the base64 encoded 64-bit xxHash. Fast with a low collision probability.
+func XxHash(data []byte) string {
+	return base64.StdEncoding.EncodeToString(binary.BigEndian.AppendUint64(nil, xxhash.Sum64(data)))
+}
+
+// Crc32Hash computes the base64 encoded CRC-32 checksum. Fast, but only 32 bit and therefore more prone to collisions.
+func Crc32Hash(data []byte) string {
+	return base64.StdEncoding.EncodeToString(binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(data)))
+}
+
+// HashFuncByName returns the HashFunc for the algorithm names sha256, xxhash and crc32.
+func HashFuncByName(name string) (HashFunc, error) {
+	switch name {
+	case "sha256":
+		return Sha256Hash, nil
+	case "xxhash":
+		return XxHash, nil
+	case "crc32":
+		return Crc32Hash, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownHashAlgorithm, name)
+	}
+}
+
+// CacheOptions holds the options for the NewCacheHandlerWithOptions.
+type CacheOptions struct {
+	// Hash computes the ETag
+	Hash HashFunc
+	// MaxEntries is the maximum number of stored ETags, the least recently used are evicted. 0 uses DefaultCacheMaxEntries.
+	MaxEntries int
+	// FS is the filesystem that the next handler serves. If set, the stored ETags are keyed by the size and modification time
+	// of the served file in addition to the request path, so that changed files are hashed again. Otherwise, the files
+	// are assumed to not change, e.g. for an in-memory filesystem.
+	FS fs.FS
+	// ContentDigest adds the RFC 9530 Content-Digest HTTP-Header with the SHA-256 hash of the response body to GET responses.
+	// The hash covers the bytes as sent, i.e. after the Content-Encoding like gzip has been applied when the handler
+	// wraps the compression. Only full HTTP 200 responses get the header, partial range responses do not.
+	ContentDigest bool
+}
+
+// cacheKey identifies a version of a served file. size is -1 if the file could not be determined.
+type cacheKey struct {
+	path    string
+	size    int64
+	modTime int64
+}
+
+// cacheEntry holds the hashes for a cacheKey
+type cacheEntry struct {
+	eTag string
+	// digest is only set if the Content-Digest HTTP-Header is active
+	digest *contentDigest
+}
+
+// contentDigest is the stored Content-Digest HTTP-Header value together with the Content-Encoding of the hashed bytes
+type contentDigest struct {
+	value           string
+	contentEncoding string
+}
+
 // cacheHandler implements a http.Handler that supports Caching via the ETag and If-None-Match HTTP-Headers.
 // The CacheHandler required that all following handlers only serve static resources.
 // The next handler in the chain is only called when a cache mismatch occurs.
+// At most MaxEntries hashes are stored, the least recently used are evicted.
+// Concurrent requests for a path whose hash is missing share the response of a single call of the next handler,
+// which also computes the hash.
 type cacheHandler struct {
-	Next   http.Handler
-	Hashes *xsync.MapOf[string, string]
+	Next          http.Handler
+	Entries       *lruCache[cacheKey, cacheEntry]
+	Hash          HashFunc
+	FS            fs.FS
+	contentDigest bool
+	fills         singleflight.Group
 }
 
-//nolint:contextcheck // context is obtained from request
 func (handler *cacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	eTag, ok := handler.Hashes.Load(r.URL.Path)
-	if ok {
-		if r.Header.Get("If-None-Match") == eTag {
-			log.Debug().Msgf("Returned not modified for %s: %s", r.URL.Path, eTag)
-			w.WriteHeader(http.StatusNotModified)
-			return
-		}
-		// we have the hash but not present in the request, add e-tag and continue
-		log.Debug().Msgf("Returned already stored eTag for %s: %s", r.URL.Path, eTag)
-		w.Header().Set("ETag", eTag)
+	key := handler.cacheKey(r)
+	if handler.serveCached(w, r, key) {
+		return
+	}
+	if r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+		// the hash can only be computed from a full response body, which HEAD and range responses lack
 		handler.Next.ServeHTTP(w, r)
 		return
 	}
-
-	// We do not have the hash yet, get it and add ETag
-	status := http.StatusOK
-	pr, pw := io.Pipe()
-	defer utils.Close(r.Context(), pr)
-	wrappedW := httpsnoop.Wrap(w, httpsnoop.Hooks{
-		WriteHeader: func(headerFunc httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
-			return func(code int) {
-				status = code
-				headerFunc(code)
-			}
-		},
-		Write: func(writeFunc httpsnoop.WriteFunc) httpsnoop.WriteFunc {
-			return func(b []byte) (int, error) {
-				if status == http.StatusOK {
-					return pw.Write(b)
-				}
-				return writeFunc(b)
-			}
-		},
-		ReadFrom: func(fromFunc httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
-			return func(src io.Reader) (int64, error) {
-				if status == http.StatusOK {
-					return io.Copy(pw, src)
-				}
-				return fromFunc(src)
-			}
-		},
+	// the response may depend on the Content-Encoding, e.g. if the next handler compresses
+	result, _, _ := handler.fills.Do(r.URL.Path+"\n"+r.Header.Get("Accept-Encoding"), func() (any, error) {
+		if _, ok := handler.Entries.Load(key); ok {
+			// the hash has been stored after the serveCached check
+			return nil, nil
+		}
+		return handler.captureAndHash(w.Header(), r, key), nil
 	})
-	go func() {
-		handler.Next.ServeHTTP(wrappedW, r)
-		utils.Close(r.Context(), pw)
-	}()
-	data, err := io.ReadAll(pr)
-	if status != http.StatusOK {
+	// the responses are written outside the singleflight group, so that the waiting requests do not depend on the client of the leader
+	if response, ok := result.(*capturedResponse); ok {
+		handler.writeCaptured(w, r, response)
 		return
 	}
-	if err != nil {
-		log.Err(err).Msgf("error storing response in middleware to determine hash %s", r.URL.Path)
-		http.Error(w, "Error serving file.", http.StatusInternalServerError)
+	// another request has computed the hash in the meantime
+	if handler.serveCached(w, r, key) {
+		return
 	}
-	hash := sha256.Sum256(data)
-	eTag = base64.StdEncoding.EncodeToString(hash[:])
-	log.Debug().Msgf("Computed missing eTag for %s: %s", r.URL.Path, eTag)
-	handler.Hashes.Store(r.URL.Path, eTag)
+	handler.writeCaptured(w, r, handler.captureAndHash(w.Header(), r, key))
+}
+
+// cacheKey returns the key for the served file. Like the http.FileServer, directories are served via their index.html.
+func (handler *cacheHandler) cacheKey(r *http.Request) cacheKey {
+	key := cacheKey{path: r.URL.Path, size: -1}
+	if handler.FS == nil {
+		return key
+	}
+	name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	if name == "" {
+		name = "."
+	}
+	info, err := fs.Stat(handler.FS, name)
+	if err == nil && info.IsDir() {
+		info, err = fs.Stat(handler.FS, path.Join(name, "index.html"))
+	}
+	if err == nil {
+		key.size = info.Size()
+		key.modTime = info.ModTime().UnixNano()
+	}
+	return key
+}
+
+// serveCached serves the request if the hash for the key is already known. Returns whether the request has been served.
+func (handler *cacheHandler) serveCached(w http.ResponseWriter, r *http.Request, key cacheKey) bool {
+	entry, ok := handler.Entries.Load(key)
+	if !ok {
+		return false
+	}
+	eTag := entry.eTag
+	if r.Header.Get("If-None-Match") == eTag {
+		requestLogger(r.Context()).Debug().Msgf("Returned not modified for %s: %s", r.URL.Path, eTag)
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	// we have the hash but not present in the request, add e-tag and continue
+	requestLogger(r.Context()).Debug().Msgf("Returned already stored eTag for %s: %s", r.URL.Path, eTag)
 	w.Header().Set("ETag", eTag)
+	// the digest is only sent for GET requests
+	if digest := entry.digest; digest != nil && r.Method != http.MethodHead {
+		w = wrapWriteHeader(w, func(code int) {
+			// the digest only matches the full response with the same Content-Encoding
+			if code == http.StatusOK && w.Header().Get("Content-Encoding") == digest.contentEncoding {
+				w.Header().Set("Content-Digest", digest.value)
+			}
+		})
+	}
+	handler.Next.ServeHTTP(w, r)
+	return true
+}
+
+// capturedResponse is a response of the next handler that has been captured to compute the hash.
+// It is shared by the concurrent requests for the same path and must not be modified.
+type capturedResponse struct {
+	status int
+	// initialHeader are the HTTP-Headers before the next handler has been called, header the ones of the response
+	initialHeader http.Header
+	header        http.Header
+	body          []byte
+	// eTag and digest are only set for HTTP 200 responses, digest only if the Content-Digest HTTP-Header is active
+	eTag   string
+	digest string
+}
+
+// captureWriter captures the status, headers and body of a response
+type captureWriter struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *captureWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *captureWriter) WriteHeader(code int) {
+	// informational responses are not captured
+	if w.wroteHeader || code < http.StatusOK {
+		return
+	}
+	w.status = code
+	w.wroteHeader = true
+}
 
-	_, err = io.Copy(w, bytes.NewReader(data))
-	if err != nil {
-		log.Err(err).Msgf("error coping response in middleware after determining hash %s", r.URL.Path)
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.body.Write(b)
+}
+
+// captureAndHash captures the response of the next handler and computes and stores the hash for HTTP 200 responses.
+// The header are the HTTP-Headers that have been set before, the next handler works on a copy of them.
+func (handler *cacheHandler) captureAndHash(header http.Header, r *http.Request, key cacheKey) *capturedResponse {
+	captured := &captureWriter{header: header.Clone(), status: http.StatusOK}
+	handler.Next.ServeHTTP(captured, r)
+	response := &capturedResponse{status: captured.status, initialHeader: header.Clone(), header: captured.header, body: captured.body.Bytes()}
+	if response.status != http.StatusOK {
+		return response
+	}
+	entry := cacheEntry{eTag: handler.Hash(response.body)}
+	requestLogger(r.Context()).Debug().Msgf("Computed missing eTag for %s: %s", r.URL.Path, entry.eTag)
+	response.eTag = entry.eTag
+	if handler.contentDigest {
+		entry.digest = &contentDigest{value: "sha-256=:" + Sha256Hash(response.body) + ":", contentEncoding: response.header.Get("Content-Encoding")}
+		response.digest = entry.digest.value
+	}
+	handler.Entries.Store(key, entry)
+	return response
+}
+
+// writeCaptured writes the captured response together with the computed hashes.
+// Only the changes of the next handler to the HTTP-Headers are applied, so that requests that share the response
+// keep their own HTTP-Headers like cookies.
+func (handler *cacheHandler) writeCaptured(w http.ResponseWriter, r *http.Request, response *capturedResponse) {
+	if response.eTag != "" && r.Header.Get("If-None-Match") == response.eTag {
+		w.Header().Set("ETag", response.eTag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	for name := range response.initialHeader {
+		if _, ok := response.header[name]; !ok {
+			w.Header().Del(name)
+		}
 	}
+	for name, values := range response.header {
+		if !slices.Equal(values, response.initialHeader[name]) {
+			w.Header()[name] = slices.Clone(values)
+		}
+	}
+	if response.eTag != "" {
+		w.Header().Set("ETag", response.eTag)
+	}
+	if response.digest != "" {
+		w.Header().Set("Content-Digest", response.digest)
+	}
+	w.WriteHeader(response.status)
+	// write errors are reported by the WriteErrorHandler
+	_, _ = w.Write(response.body)
 }
 
-// NewCacheHandler computes and stores the hashes for all files
+// NewCacheHandler computes and stores the SHA-256 hashes for all files, at most DefaultCacheMaxEntries hashes are stored.
 func NewCacheHandler(next http.Handler) *cacheHandler {
-	// compute hashes
+	return NewCacheHandlerWithHash(next, Sha256Hash, DefaultCacheMaxEntries)
+}
+
+// NewCacheHandlerWithHash computes and stores the hashes for all files with the given hash function.
+// At most maxEntries hashes are stored.
+func NewCacheHandlerWithHash(next http.Handler, hash HashFunc, maxEntries int) *cacheHandler {
+	return NewCacheHandlerWithOptions(next, CacheOptions{Hash: hash, MaxEntries: maxEntries})
+}
+
+// NewCacheHandlerWithOptions computes and stores the hashes for all files with the given CacheOptions.
+func NewCacheHandlerWithOptions(next http.Handler, opts CacheOptions) *cacheHandler {
+	maxEntries := opts.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheMaxEntries
+	}
 	return &cacheHandler{
-		Next:   next,
-		Hashes: xsync.NewMapOf[string](),
+		Next:          next,
+		Entries:       newLruCache[cacheKey, cacheEntry](maxEntries),
+		Hash:          opts.Hash,
+		FS:            opts.FS,
+		contentDigest: opts.ContentDigest,
 	}
 }
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ImmutableCacheControl is the Cache-Control HTTP-Header value for resources that never change under the same URL.
+const ImmutableCacheControl = "public, max-age=31536000, immutable"
+
+// CacheBustingQueryHandler sets the Cache-Control HTTP-Header to ImmutableCacheControl for successful responses
+// if the given cache-busting query parameter is present in the request, e.g. for app.js?v=123.
+// Responses served via a FallbackHandler further down the handler chain are not marked as immutable.
+func CacheBustingQueryHandler(next http.Handler, queryParam string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !r.URL.Query().Has(queryParam) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r, decision := withFallbackDecision(r)
+		next.ServeHTTP(wrapWriteHeader(w, func(code int) {
+			if code == http.StatusOK && !decision.fallback {
+				w.Header().Set("Cache-Control", ImmutableCacheControl)
+			}
+		}), r)
+	})
+}
+
+// CacheControlRule sets the Cache-Control HTTP-Header to Value for request paths that match the Path.
+// The Value is passed through verbatim, e.g. "public, max-age=60, stale-while-revalidate=600, stale-if-error=86400".
+type CacheControlRule struct {
+	Path  *regexp.Regexp
+	Value string
+}
+
+// CacheControlRulesHandler sets the Cache-Control HTTP-Header of successful and HTTP 304 responses according to the first matching rule.
+// HTTP 304 responses carry the same value as the full response, so that revalidations via ETag (e.g. after stale-while-revalidate) renew it.
+// Handlers further up the handler chain like the CacheBustingQueryHandler or FallbackHandler may override the value.
+func CacheControlRulesHandler(next http.Handler, rules []CacheControlRule) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, rule := range rules {
+			if !rule.Path.MatchString(r.URL.Path) {
+				continue
+			}
+			next.ServeHTTP(wrapWriteHeader(w, func(code int) {
+				if code < http.StatusMultipleChoices || code == http.StatusNotModified {
+					w.Header().Set("Cache-Control", rule.Value)
+				}
+			}), r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HashedPathHandler supports cache-busting via a hash segment in the request path like /assets/<hash>/app.js.
+// If the path segment at the zero-based segment index matches the pattern, it is removed from the path before
+// the request is passed to the next handler and successful responses are marked as immutable.
+func HashedPathHandler(next http.Handler, segment int, pattern *regexp.Regexp) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// leading empty segment due to the absolute path
+		segments := strings.Split(r.URL.Path, "/")
+		if segment < 0 || segment+1 >= len(segments)-1 || !pattern.MatchString(segments[segment+1]) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = strings.Join(append(segments[:segment+1:segment+1], segments[segment+2:]...), "/")
+		r2.URL.RawPath = ""
+		next.ServeHTTP(wrapWriteHeader(w, func(code int) {
+			if code == http.StatusOK {
+				w.Header().Set("Cache-Control", ImmutableCacheControl)
+			}
+		}), r2)
+	})
+}
@@ -0,0 +1,131 @@
+package server_test
+
+import (
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"testing/fstest"
+)
+
+const cacheBustingParam = "v"
+
+func TestCacheBustingQuery(t *testing.T) {
+	result := getCacheBustingResponse(t, "v=123", http.StatusOK)
+	require.Equal(t, server.ImmutableCacheControl, result.Header.Get("Cache-Control"))
+}
+
+func TestCacheBustingQueryAbsent(t *testing.T) {
+	result := getCacheBustingResponse(t, "w=123", http.StatusOK)
+	require.Equal(t, "no-cache", result.Header.Get("Cache-Control"))
+}
+
+func TestCacheBustingQueryNotFound(t *testing.T) {
+	result := getCacheBustingResponse(t, "v=123", http.StatusNotFound)
+	require.Equal(t, "no-cache", result.Header.Get("Cache-Control"))
+}
+
+func getCacheBustingResponse(t *testing.T, query string, status int) *http.Response {
+	w, r, next := getDefaultHandlerMocks()
+	w.Header().Set("Cache-Control", "no-cache")
+	next.serveHttpFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}
+	r.URL = &url.URL{Path: "/app.js", RawQuery: query}
+	handler := server.CacheBustingQueryHandler(next, cacheBustingParam)
+	handler.ServeHTTP(w, r)
+	result := w.Result()
+	t.Cleanup(func() {
+		err := result.Body.Close()
+		require.NoError(t, err)
+	})
+	return result
+}
+
+var hashedPathPattern = regexp.MustCompile("^[0-9a-f]{8}$")
+
+func TestHashedPath(t *testing.T) {
+	result := getHashedPathResponse(t, "/assets/0123abcd/app.js")
+	require.Equal(t, http.StatusOK, result.StatusCode)
+	require.Equal(t, "app", string(getReceivedData(t, result.Body)))
+	require.Equal(t, server.ImmutableCacheControl, result.Header.Get("Cache-Control"))
+}
+
+func TestHashedPathNoMatch(t *testing.T) {
+	result := getHashedPathResponse(t, "/assets/app.js")
+	require.Equal(t, http.StatusOK, result.StatusCode)
+	require.Empty(t, result.Header.Get("Cache-Control"))
+
+	// the hash segment is never the file name itself
+	result = getHashedPathResponse(t, "/assets/0123abcd")
+	require.Equal(t, http.StatusNotFound, result.StatusCode)
+	require.Empty(t, result.Header.Get("Cache-Control"))
+}
+
+func TestHashedPathNotFound(t *testing.T) {
+	result := getHashedPathResponse(t, "/assets/0123abcd/missing.js")
+	require.Equal(t, http.StatusNotFound, result.StatusCode)
+	require.Empty(t, result.Header.Get("Cache-Control"))
+}
+
+func getHashedPathResponse(t *testing.T, requestPath string) *http.Response {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, requestPath, nil)
+	fs := fstest.MapFS{"assets/app.js": {Data: []byte("app")}}
+	handler := server.HashedPathHandler(http.FileServer(http.FS(fs)), 1, hashedPathPattern)
+	handler.ServeHTTP(w, r)
+	result := w.Result()
+	t.Cleanup(func() {
+		err := result.Body.Close()
+		require.NoError(t, err)
+	})
+	return result
+}
+
+const staleCacheControl = "public, max-age=60, stale-while-revalidate=600, stale-if-error=86400"
+
+func TestCacheControlRules(t *testing.T) {
+	rules := []server.CacheControlRule{
+		{Path: regexp.MustCompile(`^/docs/`), Value: staleCacheControl},
+		{Path: regexp.MustCompile(`\.js$`), Value: "no-cache"},
+	}
+	for _, tc := range []struct {
+		target       string
+		status       int
+		cacheControl string
+	}{
+		{target: "/docs/app.js", status: http.StatusOK, cacheControl: staleCacheControl},
+		{target: "/docs/app.js", status: http.StatusNotModified, cacheControl: staleCacheControl},
+		{target: "/docs/app.js", status: http.StatusNotFound, cacheControl: ""},
+		{target: "/app.js", status: http.StatusOK, cacheControl: "no-cache"},
+		{target: "/app.css", status: http.StatusOK, cacheControl: ""},
+	} {
+		w := httptest.NewRecorder()
+		handler := server.CacheControlRulesHandler(getStatusHandler(tc.status), rules)
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.target, nil))
+		require.Equal(t, tc.cacheControl, w.Header().Get("Cache-Control"), tc.target)
+	}
+}
+
+func TestCacheControlRulesPrecedence(t *testing.T) {
+	rules := []server.CacheControlRule{{Path: regexp.MustCompile(`.*`), Value: staleCacheControl}}
+	handler := server.CacheBustingQueryHandler(server.CacheControlRulesHandler(getStatusHandler(http.StatusOK), rules), cacheBustingParam)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/app.js?v=1", nil))
+	require.Equal(t, server.ImmutableCacheControl, w.Header().Get("Cache-Control"))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/app.js", nil))
+	require.Equal(t, staleCacheControl, w.Header().Get("Cache-Control"))
+}
+
+// getStatusHandler returns a handler that always responds with the given status code
+func getStatusHandler(status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})
+}
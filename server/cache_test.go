@@ -1,10 +1,19 @@
 package server_test
 
 import (
+	"compress/gzip"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/ngergs/websrv/v3/server"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -26,9 +35,8 @@ func TestEtagSetting(t *testing.T) {
 		require.NoError(t, err)
 	}()
 	require.Equal(t, http.StatusOK, result.StatusCode)
-	hash, ok := cacheHandler.Hashes.Load(path)
-	require.True(t, ok)
-	require.Equal(t, hash, w.Header().Get("ETag"))
+	require.Equal(t, 1, cacheHandler.Entries.Size())
+	require.Equal(t, server.Sha256Hash([]byte{}), w.Header().Get("ETag"))
 }
 func TestNoEtagOnError(t *testing.T) {
 	path := "dummy_random.js"
@@ -50,9 +58,8 @@ func TestNotModifiedResponse(t *testing.T) {
 	cacheHandler := server.NewCacheHandler(next)
 	r.URL = &url.URL{Path: path}
 	cacheHandler.ServeHTTP(w, r) // initial request to warm up the cache
-	hash, ok := cacheHandler.Hashes.Load(path)
-	require.True(t, ok)
-	r.Header.Set("If-None-Match", hash)
+	require.Equal(t, 1, cacheHandler.Entries.Size())
+	r.Header.Set("If-None-Match", w.Header().Get("ETag"))
 	w, _, _ = getDefaultHandlerMocks()
 	cacheHandler.ServeHTTP(w, r)
 	result := w.Result()
@@ -62,3 +69,274 @@ func TestNotModifiedResponse(t *testing.T) {
 	}()
 	require.Equal(t, http.StatusNotModified, result.StatusCode)
 }
+
+func TestEtagHashFunc(t *testing.T) {
+	for _, name := range []string{"sha256", "xxhash", "crc32"} {
+		hash, err := server.HashFuncByName(name)
+		require.NoError(t, err)
+		w, r, next := getDefaultHandlerMocks()
+		next.serveHttpFunc = func(w http.ResponseWriter, r *http.Request) {
+			_, err := w.Write([]byte(dummyResponse))
+			assert.NoError(t, err)
+		}
+		cacheHandler := server.NewCacheHandlerWithHash(next, hash, server.DefaultCacheMaxEntries)
+		r.URL = &url.URL{Path: path}
+		cacheHandler.ServeHTTP(w, r)
+		require.Equal(t, hash([]byte(dummyResponse)), w.Header().Get("ETag"))
+	}
+	_, err := server.HashFuncByName("md5")
+	require.ErrorIs(t, err, server.ErrUnknownHashAlgorithm)
+}
+
+func TestEtagMaxEntries(t *testing.T) {
+	_, r, next := getDefaultHandlerMocks()
+	var served atomic.Int32
+	next.serveHttpFunc = func(w http.ResponseWriter, r *http.Request) {
+		served.Add(1)
+		_, err := w.Write([]byte(r.URL.Path))
+		assert.NoError(t, err)
+	}
+	cacheHandler := server.NewCacheHandlerWithHash(next, server.XxHash, 2)
+	for _, requestPath := range []string{"/a.js", "/b.js", "/a.js", "/c.js"} {
+		w, _, _ := getDefaultHandlerMocks()
+		r.URL = &url.URL{Path: requestPath}
+		cacheHandler.ServeHTTP(w, r)
+		require.Equal(t, server.XxHash([]byte(requestPath)), w.Header().Get("ETag"))
+	}
+	require.Equal(t, 2, cacheHandler.Entries.Size())
+
+	// /b.js has been evicted as least recently used, the not modified response for /a.js does not call the next handler
+	served.Store(0)
+	w, _, _ := getDefaultHandlerMocks()
+	r.URL = &url.URL{Path: "/a.js"}
+	r.Header.Set("If-None-Match", server.XxHash([]byte("/a.js")))
+	cacheHandler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusNotModified, w.Code)
+	require.Equal(t, int32(0), served.Load())
+	r.URL = &url.URL{Path: "/b.js"}
+	r.Header.Set("If-None-Match", server.XxHash([]byte("/b.js")))
+	cacheHandler.ServeHTTP(httptest.NewRecorder(), r)
+	require.Equal(t, int32(1), served.Load())
+}
+
+func TestEtagDefaultMaxEntries(t *testing.T) {
+	w, r, next := getDefaultHandlerMocks()
+	cacheHandler := server.NewCacheHandlerWithHash(next, server.XxHash, 0)
+	r.URL = &url.URL{Path: "/a.js"}
+	cacheHandler.ServeHTTP(w, r)
+	require.Equal(t, 1, cacheHandler.Entries.Size())
+}
+
+func TestEtagChangedFile(t *testing.T) {
+	fsys := fstest.MapFS{"index.js": {Data: []byte("old"), ModTime: time.Unix(1, 0)}}
+	handler := server.NewCacheHandlerWithOptions(server.FileServer(fsys), server.CacheOptions{Hash: server.XxHash, FS: fsys})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/index.js", nil))
+	require.Equal(t, server.XxHash([]byte("old")), w.Header().Get("ETag"))
+
+	// same size, but a different modification time
+	fsys["index.js"] = &fstest.MapFile{Data: []byte("new"), ModTime: time.Unix(2, 0)}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/index.js", nil))
+	require.Equal(t, "new", w.Body.String())
+	require.Equal(t, server.XxHash([]byte("new")), w.Header().Get("ETag"))
+}
+
+func TestEtagHeadDoesNotFill(t *testing.T) {
+	fsys := fstest.MapFS{"index.js": {Data: []byte(dummyResponse)}}
+	handler := server.NewCacheHandler(server.FileServer(fsys))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/index.js", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, 0, handler.Entries.Size())
+
+	// the ETag is computed from the body of the GET response and then also used for HEAD requests
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, "/index.js", nil))
+		require.Equal(t, server.Sha256Hash([]byte(dummyResponse)), w.Header().Get("ETag"))
+	}
+}
+
+func TestEtagConcurrentFill(t *testing.T) {
+	var hashCount, nextCount atomic.Int32
+	hash := func(data []byte) string {
+		hashCount.Add(1)
+		return server.XxHash(data)
+	}
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nextCount.Add(1)
+		once.Do(func() { close(started) })
+		<-release
+		w.Header().Set("Content-Type", "text/javascript")
+		_, err := w.Write([]byte(dummyResponse))
+		assert.NoError(t, err)
+	})
+	// the HTTP-Headers that have been set before, like cookies, are kept per request
+	var requestCount atomic.Int32
+	cacheHandler := server.NewCacheHandlerWithHash(next, hash, server.DefaultCacheMaxEntries)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", strconv.Itoa(int(requestCount.Add(1))))
+		cacheHandler.ServeHTTP(w, r)
+	})
+
+	responses := serveConcurrently(handler, path, started, release)
+	require.Equal(t, int32(1), nextCount.Load())
+	require.Equal(t, int32(1), hashCount.Load())
+	cookies := make(map[string]bool)
+	for _, w := range responses {
+		require.Equal(t, dummyResponse, w.Body.String())
+		require.Equal(t, server.XxHash([]byte(dummyResponse)), w.Header().Get("ETag"))
+		require.Equal(t, "text/javascript", w.Header().Get("Content-Type"))
+		cookies[w.Header().Get("Set-Cookie")] = true
+	}
+	require.Len(t, cookies, len(responses))
+}
+
+func TestEtagColdNotModified(t *testing.T) {
+	fsys := fstest.MapFS{"index.js": {Data: []byte(dummyResponse)}}
+	handler := server.NewCacheHandler(server.FileServer(fsys))
+	r := httptest.NewRequest(http.MethodGet, "/index.js", nil)
+	// e.g. a client that has cached the response before a restart
+	r.Header.Set("If-None-Match", server.Sha256Hash([]byte(dummyResponse)))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusNotModified, w.Code)
+	require.Empty(t, w.Body.String())
+}
+
+func TestEtagConcurrentFillSlowClient(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { close(started) })
+		<-release
+		_, err := w.Write([]byte(dummyResponse))
+		assert.NoError(t, err)
+	})
+	cacheHandler := server.NewCacheHandler(next)
+
+	slowClient := &blockingWriter{ResponseRecorder: httptest.NewRecorder(), release: make(chan struct{})}
+	slowDone := make(chan struct{})
+	go func() {
+		defer close(slowDone)
+		_, r, _ := getDefaultHandlerMocks()
+		r.URL = &url.URL{Path: path}
+		cacheHandler.ServeHTTP(slowClient, r)
+	}()
+	<-started
+	waiterDone := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w, r, _ := getDefaultHandlerMocks()
+		r.URL = &url.URL{Path: path}
+		cacheHandler.ServeHTTP(w, r)
+		waiterDone <- w
+	}()
+	close(release)
+
+	// the waiting request is served while the client of the request that computed the hash still blocks
+	w := <-waiterDone
+	require.Equal(t, dummyResponse, w.Body.String())
+	require.Equal(t, server.Sha256Hash([]byte(dummyResponse)), w.Header().Get("ETag"))
+	close(slowClient.release)
+	<-slowDone
+	require.Equal(t, dummyResponse, slowClient.Body.String())
+}
+
+// blockingWriter blocks the body writes until release is closed
+type blockingWriter struct {
+	*httptest.ResponseRecorder
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(b []byte) (int, error) {
+	<-w.release
+	return w.ResponseRecorder.Write(b)
+}
+
+// serveConcurrently serves concurrent requests for the requestPath. The further requests are started once the next handler
+// has signaled via started that it serves the first request, the release channel is closed after all requests have entered the handler.
+func serveConcurrently(handler http.Handler, requestPath string, started chan struct{}, release chan struct{}) []*httptest.ResponseRecorder {
+	const concurrentRequests = 10
+	var wg, entered sync.WaitGroup
+	responses := make([]*httptest.ResponseRecorder, concurrentRequests)
+	for i := range responses {
+		w, r, _ := getDefaultHandlerMocks()
+		r.URL = &url.URL{Path: requestPath}
+		responses[i] = w
+		wg.Add(1)
+		entered.Add(1)
+		go func() {
+			defer wg.Done()
+			entered.Done()
+			handler.ServeHTTP(w, r)
+		}()
+		if i == 0 {
+			<-started
+		}
+	}
+	entered.Wait()
+	close(release)
+	wg.Wait()
+	return responses
+}
+
+func TestEtagFirstResponse(t *testing.T) {
+	fsys := fstest.MapFS{"index.js": {Data: []byte(dummyResponse)}}
+	srv := httptest.NewServer(server.NewCacheHandler(server.FileServer(fsys)))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/index.js")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, server.Sha256Hash([]byte(dummyResponse)), resp.Header.Get("ETag"))
+}
+
+func TestContentDigest(t *testing.T) {
+	fsys := fstest.MapFS{"index.js": {Data: []byte(dummyResponse)}}
+	handler := server.NewCacheHandlerWithOptions(server.FileServer(fsys), server.CacheOptions{
+		Hash: server.XxHash, MaxEntries: server.DefaultCacheMaxEntries, ContentDigest: true,
+	})
+	expected := "sha-256=:" + server.Sha256Hash([]byte(dummyResponse)) + ":"
+	// the second request is served with the stored digest
+	for range 2 {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/index.js", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, expected, w.Header().Get("Content-Digest"))
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/index.js", nil))
+	require.Empty(t, w.Header().Get("Content-Digest"))
+
+	r := httptest.NewRequest(http.MethodGet, "/index.js", nil)
+	r.Header.Set("Range", "bytes=0-0")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	require.Empty(t, w.Header().Get("Content-Digest"))
+}
+
+func TestContentDigestCompressed(t *testing.T) {
+	fsys := fstest.MapFS{"index.js": {Data: []byte(strings.Repeat("compressible ", 100))}}
+	handler := server.NewCacheHandlerWithOptions(middleware.Compress(gzip.DefaultCompression, "text/javascript")(server.FileServer(fsys)),
+		server.CacheOptions{Hash: server.Sha256Hash, MaxEntries: server.DefaultCacheMaxEntries, ContentDigest: true})
+
+	// the digest covers the gzip encoded bytes
+	for range 2 {
+		r := httptest.NewRequest(http.MethodGet, "/index.js", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		require.Equal(t, "sha-256=:"+server.Sha256Hash(w.Body.Bytes())+":", w.Header().Get("Content-Digest"))
+	}
+
+	// the stored digest does not match the unencoded response
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/index.js", nil))
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+	require.Empty(t, w.Header().Get("Content-Digest"))
+}
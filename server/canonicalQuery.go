@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// CanonicalQueryOptions configures the query string canonicalization.
+type CanonicalQueryOptions struct {
+	// Ignore are the query parameters that are dropped, a trailing * matches all parameters with the prefix like utm_*
+	Ignore []string
+	// Redirect responds with HTTP 301 to the canonical URL instead of rewriting the request internally
+	Redirect bool
+}
+
+// CanonicalQueryHandler canonicalizes the query string of GET and HEAD requests to improve the hit rate of caching proxies.
+// The parameters are sorted by key (the order of the values per key is kept), duplicate key-value pairs and the ignored parameters are dropped.
+// Query strings that can not be parsed are passed unchanged to the next handler.
+func CanonicalQueryHandler(next http.Handler, options CanonicalQueryOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.Method != http.MethodGet && r.Method != http.MethodHead) || (r.URL.RawQuery == "" && !r.URL.ForceQuery) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		canonical, err := canonicalQuery(r.URL.RawQuery, options.Ignore)
+		if err != nil || (canonical == r.URL.RawQuery && !r.URL.ForceQuery) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		canonicalUrl := new(url.URL)
+		*canonicalUrl = *r.URL
+		canonicalUrl.RawQuery = canonical
+		canonicalUrl.ForceQuery = false
+		if options.Redirect {
+			http.Redirect(w, r, canonicalUrl.RequestURI(), http.StatusMovedPermanently)
+			return
+		}
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = canonicalUrl
+		r2.RequestURI = canonicalUrl.RequestURI()
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// canonicalQuery returns the query sorted by key without duplicate key-value pairs and the ignored parameters.
+func canonicalQuery(rawQuery string, ignore []string) (string, error) {
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", err
+	}
+	for key, values := range query {
+		if isIgnoredQueryParam(key, ignore) {
+			delete(query, key)
+			continue
+		}
+		deduplicated := values[:0]
+		for _, value := range values {
+			if !slices.Contains(deduplicated, value) {
+				deduplicated = append(deduplicated, value)
+			}
+		}
+		query[key] = deduplicated
+	}
+	// Encode sorts by key
+	return query.Encode(), nil
+}
+
+func isIgnoredQueryParam(key string, ignore []string) bool {
+	for _, pattern := range ignore {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == pattern {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package server_test
+
+import (
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCanonicalQuery(t *testing.T) {
+	options := server.CanonicalQueryOptions{Ignore: []string{"utm_*", "fbclid"}}
+	for _, tc := range []struct {
+		target   string
+		expected string
+	}{
+		{target: "/app.js?b=2&a=1", expected: "a=1&b=2"},
+		{target: "/app.js?a=2&a=1&a=2", expected: "a=2&a=1"},
+		{target: "/app.js?v=1&utm_source=mail&utm_medium=x&fbclid=abc", expected: "v=1"},
+		{target: "/app.js?utm_source=mail", expected: ""},
+		{target: "/app.js?", expected: ""},
+		{target: "/app.js?a=1&b=2", expected: "a=1&b=2"},
+		{target: "/app.js?a=%zz", expected: "a=%zz"},
+	} {
+		t.Run(tc.target, func(t *testing.T) {
+			var receivedQuery string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				receivedQuery = r.URL.RawQuery
+			})
+			w := httptest.NewRecorder()
+			server.CanonicalQueryHandler(next, options).ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.target, nil))
+			require.Equal(t, http.StatusOK, w.Code)
+			require.Equal(t, tc.expected, receivedQuery)
+		})
+	}
+}
+
+func TestCanonicalQueryRedirect(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := server.CanonicalQueryHandler(next, server.CanonicalQueryOptions{Ignore: []string{"utm_*"}, Redirect: true})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/app.js?b=2&utm_source=mail&a=1", nil))
+	require.Equal(t, http.StatusMovedPermanently, w.Code)
+	require.Equal(t, "/app.js?a=1&b=2", w.Header().Get("Location"))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/app.js?a=1&b=2", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/app.js?b=2&a=1", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+}
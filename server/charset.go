@@ -0,0 +1,112 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"mime"
+	"net/http"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/ngergs/websrv/v3/internal/utils"
+)
+
+const (
+	charsetUtf8        = "utf-8"
+	charsetUtf16LE     = "utf-16le"
+	charsetUtf16BE     = "utf-16be"
+	charsetWindows1252 = "windows-1252"
+)
+
+var (
+	utf16LEBom = []byte{0xFF, 0xFE}
+	utf16BEBom = []byte{0xFE, 0xFF}
+)
+
+// windows1252 maps the bytes 0x80 to 0x9F to their code points, the other bytes match the code points like in ISO-8859-1.
+// Browsers decode ISO-8859-1 labeled content as windows-1252 as well.
+var windows1252 = [32]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021, 0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014, 0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+}
+
+// CharsetOptions holds the options for the CharsetHandler.
+type CharsetOptions struct {
+	// MediaTypes are the text media types like text/plain for which the charset is detected
+	MediaTypes []string
+	// Transcode converts the content to UTF-8 instead of only declaring the detected charset
+	Transcode bool
+}
+
+// CharsetHandler detects the charset of responses whose media type is part of the options MediaTypes and sets it as charset
+// parameter of the Content-Type HTTP-Header. A UTF-8 or UTF-16 byte order mark determines the charset, otherwise valid UTF-8
+// is assumed to be UTF-8 and everything else to be windows-1252, the superset of ISO-8859-1 (Latin-1) that browsers use.
+// If Transcode is set, UTF-16 and windows-1252 content is converted to UTF-8.
+func CharsetHandler(next http.Handler, opts CharsetOptions) http.Handler {
+	return transformHandler(next, func(_ int, header http.Header) bool {
+		return utils.Contains(opts.MediaTypes, getMediaType(header))
+	}, func(header http.Header, body []byte) []byte {
+		charset := detectCharset(body)
+		if opts.Transcode && charset != charsetUtf8 {
+			body = transcodeToUtf8(body, charset)
+			charset = charsetUtf8
+		}
+		setCharset(header, charset)
+		return body
+	})
+}
+
+// detectCharset returns the charset of the content based on the byte order mark and the UTF-8 validity.
+func detectCharset(body []byte) string {
+	switch {
+	case bytes.HasPrefix(body, utf8Bom):
+		return charsetUtf8
+	case bytes.HasPrefix(body, utf16LEBom):
+		return charsetUtf16LE
+	case bytes.HasPrefix(body, utf16BEBom):
+		return charsetUtf16BE
+	case utf8.Valid(body):
+		return charsetUtf8
+	default:
+		return charsetWindows1252
+	}
+}
+
+// transcodeToUtf8 converts UTF-16 with byte order mark or windows-1252 content to UTF-8. The byte order mark is dropped.
+func transcodeToUtf8(body []byte, charset string) []byte {
+	var runes []rune
+	switch charset {
+	case charsetUtf16LE, charsetUtf16BE:
+		var byteOrder binary.ByteOrder = binary.LittleEndian
+		if charset == charsetUtf16BE {
+			byteOrder = binary.BigEndian
+		}
+		units := make([]uint16, (len(body)-2)/2)
+		for i := range units {
+			units[i] = byteOrder.Uint16(body[2+2*i:])
+		}
+		runes = utf16.Decode(units)
+	case charsetWindows1252:
+		runes = make([]rune, len(body))
+		for i, b := range body {
+			if b >= 0x80 && b <= 0x9F {
+				runes[i] = windows1252[b-0x80]
+			} else {
+				runes[i] = rune(b)
+			}
+		}
+	default:
+		return body
+	}
+	return []byte(string(runes))
+}
+
+// setCharset sets the charset parameter of the Content-Type HTTP-Header.
+func setCharset(header http.Header, charset string) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return
+	}
+	params["charset"] = charset
+	header.Set("Content-Type", mime.FormatMediaType(mediaType, params))
+}
@@ -0,0 +1,62 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCharsetDetection(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+	}{
+		// "Grüße" in ISO-8859-1
+		{name: "latin1", data: []byte{'G', 'r', 0xFC, 0xDF, 'e'}, contentType: "text/plain; charset=windows-1252"},
+		{name: "utf8", data: []byte("Grüße"), contentType: "text/plain; charset=utf-8"},
+		{name: "utf8 bom", data: append([]byte{0xEF, 0xBB, 0xBF}, "Grüße"...), contentType: "text/plain; charset=utf-8"},
+		{name: "utf16le bom", data: []byte{0xFF, 0xFE, 'h', 0, 'i', 0}, contentType: "text/plain; charset=utf-16le"},
+		{name: "utf16be bom", data: []byte{0xFE, 0xFF, 0, 'h', 0, 'i'}, contentType: "text/plain; charset=utf-16be"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := getCharsetResponse(t, test.data, server.CharsetOptions{MediaTypes: []string{"text/plain"}})
+			require.Equal(t, test.contentType, w.Header().Get("Content-Type"))
+			require.Equal(t, test.data, w.Body.Bytes())
+		})
+	}
+}
+
+func TestCharsetTranscode(t *testing.T) {
+	opts := server.CharsetOptions{MediaTypes: []string{"text/plain"}, Transcode: true}
+	w := getCharsetResponse(t, []byte{'G', 'r', 0xFC, 0xDF, 'e', ' ', 0x80}, opts)
+	require.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	require.Equal(t, "Grüße €", w.Body.String())
+	require.Equal(t, strconv.Itoa(len("Grüße €")), w.Header().Get("Content-Length"))
+
+	w = getCharsetResponse(t, []byte{0xFF, 0xFE, 'h', 0, 0xFC, 0}, opts)
+	require.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	require.Equal(t, "hü", w.Body.String())
+}
+
+func TestCharsetOtherMediaType(t *testing.T) {
+	data := []byte{'G', 'r', 0xFC, 0xDF, 'e'}
+	w := getCharsetResponse(t, data, server.CharsetOptions{MediaTypes: []string{"text/csv"}, Transcode: true})
+	require.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	require.Equal(t, data, w.Body.Bytes())
+}
+
+// getCharsetResponse serves the data as text file with the CharsetHandler
+func getCharsetResponse(t *testing.T, data []byte, opts server.CharsetOptions) *httptest.ResponseRecorder {
+	fsys := fstest.MapFS{"file.txt": {Data: data}}
+	w := httptest.NewRecorder()
+	server.CharsetHandler(server.FileServer(fsys), opts).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/file.txt", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	return w
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/ngergs/websrv/v3/filesystem"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CircuitBreakerHandler responds with HTTP 503 and a Retry-After HTTP-Header while the circuit breaker of the filesystem
+// blocks the file accesses, instead of the HTTP 500 the file server would respond with for the ErrCircuitOpen error.
+func CircuitBreakerHandler(next http.Handler, breaker *filesystem.CircuitBreaker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if retryAfter, blocked := breaker.Blocked(); blocked {
+			w.Header().Set("Retry-After", strconv.Itoa(max(int(math.Ceil(retryAfter.Seconds())), 1)))
+			Error(w, r, "", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CircuitBreakerMetricsRegister registers a gauge for the state of the circuit breaker, 0 closed, 1 half-open and 2 open.
+func CircuitBreakerMetricsRegister(registerer prometheus.Registerer, prometheusNamespace string, breaker *filesystem.CircuitBreaker) error {
+	state := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: prometheusNamespace,
+		Subsystem: "filesystem",
+		Name:      "circuit_breaker_state",
+		Help:      "State of the filesystem circuit breaker, 0 closed, 1 half-open and 2 open.",
+	}, func() float64 {
+		return float64(breaker.State())
+	})
+	if err := registerer.Register(state); err != nil {
+		return fmt.Errorf("failed to register circuit breaker state metric: %w", err)
+	}
+	return nil
+}
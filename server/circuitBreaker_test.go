@@ -0,0 +1,60 @@
+package server_test
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ngergs/websrv/v3/filesystem"
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+// unavailableFs fails all Open calls like an unreachable network-backed filesystem
+type unavailableFs struct{}
+
+func (unavailableFs) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("backend unavailable")}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := filesystem.NewCircuitBreaker(filesystem.CircuitBreakerOptions{Threshold: 1, Cooldown: time.Hour})
+	handler := server.CircuitBreakerHandler(server.FileServer(breaker.FS(unavailableFs{})), breaker)
+
+	// the failure that opens the circuit is answered by the file server
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/main.js", nil))
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/main.js", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "3600", w.Header().Get("Retry-After"))
+}
+
+func TestCircuitBreakerMetrics(t *testing.T) {
+	breaker := filesystem.NewCircuitBreaker(filesystem.CircuitBreakerOptions{Threshold: 1, Cooldown: time.Hour})
+	registry := prometheus.NewRegistry()
+	require.NoError(t, server.CircuitBreakerMetricsRegister(registry, metricsNamespace, breaker))
+	require.Equal(t, float64(filesystem.CircuitClosed), getCircuitBreakerState(t, registry))
+
+	_, err := breaker.FS(unavailableFs{}).Open("index.html")
+	require.Error(t, err)
+	require.Equal(t, float64(filesystem.CircuitOpen), getCircuitBreakerState(t, registry))
+}
+
+func getCircuitBreakerState(t *testing.T, registry *prometheus.Registry) float64 {
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() == metricsNamespace+"_filesystem_circuit_breaker_state" {
+			return family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	require.Fail(t, "circuit breaker state metric not found")
+	return 0
+}
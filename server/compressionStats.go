@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"github.com/felixge/httpsnoop"
+	"io"
+	"net/http"
+)
+
+// compressionStatsKey is the ContextKey under which the uncompressed response size is reported to the access log
+var compressionStatsKey = &ContextKey{val: "compressionStats"}
+
+// compressionStats holds the size of the response body prior to compression.
+type compressionStats struct {
+	reported     bool
+	uncompressed int64
+}
+
+// withCompressionStats adds a compressionStats to the request context where the uncompressed response size will be reported.
+func withCompressionStats(r *http.Request) (*http.Request, *compressionStats) {
+	stats := &compressionStats{}
+	return r.WithContext(context.WithValue(r.Context(), compressionStatsKey, stats)), stats
+}
+
+// ReportUncompressedSize reports the size of the response body prior to compression to the access log.
+// This is intended for pre-compressed files where the CompressionStatsHandler can not measure the size.
+// It is a no-op if the access log does not record the compression ratio.
+func ReportUncompressedSize(ctx context.Context, size int64) {
+	if stats, ok := ctx.Value(compressionStatsKey).(*compressionStats); ok {
+		stats.reported = true
+		stats.uncompressed = size
+	}
+}
+
+// CompressionStatsHandler measures the size of the response body written by the next handler and reports it to the access log.
+// It has to be placed directly inside the compression handler. Repeated invocations for the same request, e.g. by the FallbackHandler,
+// overwrite the previous measurement.
+func CompressionStatsHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(compressionStatsKey).(*compressionStats); !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var written int64
+		wrappedW := httpsnoop.Wrap(w, httpsnoop.Hooks{
+			Write: func(writeFunc httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+				return func(b []byte) (int, error) {
+					n, err := writeFunc(b)
+					written += int64(n)
+					return n, err
+				}
+			},
+			ReadFrom: func(fromFunc httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+				return func(src io.Reader) (int64, error) {
+					n, err := fromFunc(src)
+					written += n
+					return n, err
+				}
+			},
+		})
+		next.ServeHTTP(wrappedW, r)
+		ReportUncompressedSize(r.Context(), written)
+	})
+}
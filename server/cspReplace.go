@@ -7,7 +7,7 @@ import (
 	"github.com/felixge/httpsnoop"
 	"github.com/ngergs/websrv/v3/internal/utils"
 	"github.com/puzpuzpuz/xsync"
-	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
 	"io"
 	"net/http"
 	"strings"
@@ -22,12 +22,15 @@ var (
 const CspHeaderName = "Content-Security-Policy"
 
 // CspFileHandler implements the http.Handler interface and fixes the Angular style-src CSP issue. The variableName is replaced
-// in all response contents.
+// in all response contents. Concurrent requests for a template that has not been loaded yet wait for a single request to load it.
 type CspFileHandler struct {
 	replacer     *xsync.MapOf[string, *ReplacerCollection]
+	fills        singleflight.Group
 	Next         http.Handler
 	VariableName string
 	MediaTypeMap map[string]string
+	// Metrics counts the failed template renderings per template path if set
+	Metrics *PrometheusRegistration
 }
 
 // NewCspFileHandler returns a CspFileHandler, it implements the http.Handler interface and fixes the Angular style-src CSP issue.
@@ -87,7 +90,12 @@ func (handler *CspFileHandler) loadTemplate(w http.ResponseWriter, r *http.Reque
 		ReadFrom: func(fromFunc httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
 			return func(src io.Reader) (int64, error) {
 				if status == http.StatusOK {
-					return io.Copy(pw, src)
+					n, err := io.Copy(pw, src)
+					if err != nil {
+						// fails the template loading instead of caching a truncated template
+						pw.CloseWithError(err)
+					}
+					return n, err
 				}
 				return fromFunc(src)
 			}
@@ -115,39 +123,67 @@ func (handler *CspFileHandler) loadTemplate(w http.ResponseWriter, r *http.Reque
 	return storedReplacer, nil
 }
 
-func (handler *CspFileHandler) serveFile(w http.ResponseWriter, r *http.Request, input string) error {
+// getReplacer returns the stored template or loads it. The error response of the next handler has already been written
+// if ErrHttpStatusNotOk is returned.
+func (handler *CspFileHandler) getReplacer(w http.ResponseWriter, r *http.Request) (*ReplacerCollection, error) {
 	replacer, ok := handler.replacer.Load(r.URL.Path)
 	if !ok {
-		var err error
-		replacer, err = handler.loadTemplate(w, r)
+		executed := false
+		result, err, _ := handler.fills.Do(r.URL.Path, func() (any, error) {
+			if stored, ok := handler.replacer.Load(r.URL.Path); ok {
+				// the template has been stored after the check above
+				return stored, nil
+			}
+			executed = true
+			return handler.loadTemplate(w, r)
+		})
+		if err != nil && !executed {
+			// the error response of the next handler has only been written for the request that loaded the template
+			result, err = handler.loadTemplate(w, r)
+		}
 		if err != nil {
-			return err
+			return nil, err
+		}
+		replacer, ok = result.(*ReplacerCollection)
+		if !ok {
+			return nil, fmt.Errorf("%w: unexpected type %T", ErrCachingTemplate, result)
 		}
 	}
-	w.Header().Set("Content-Type", replacer.mediaType)
-	return replacer.Replace(w, input)
+	return replacer, nil
 }
 
 func (handler *CspFileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	sessionId := getSessionId(r)
-	err := handler.serveFile(w, r, sessionId)
+	replacer, err := handler.getReplacer(w, r)
+	if errors.Is(err, ErrHttpStatusNotOk) {
+		requestLogger(r.Context()).Debug().Err(err).Msgf("template file %s not served", r.URL.Path)
+		return
+	}
 	if err != nil {
-		log.Err(err).Msgf("error serving template file %s", r.URL.Path)
-		http.Error(w, "Error serving file.", http.StatusInternalServerError)
+		requestLogger(r.Context()).Err(err).Msgf("error rendering template file %s", r.URL.Path)
+		if handler.Metrics != nil {
+			// the label set is bounded as only files that exist in the served filesystem fail here
+			handler.Metrics.templateErrors.With(map[string]string{TemplateLabel: r.URL.Path}).Inc()
+		}
+		Error(w, r, "Error serving file.", http.StatusInternalServerError)
+		return
 	}
+	w.Header().Set("Content-Type", replacer.mediaType)
+	// write errors are reported by the WriteErrorHandler
+	_ = replacer.Replace(w, sessionId)
 }
 
 // getSessionId extract the session id from the request context. Returns an empty string if it is not set.
 func getSessionId(r *http.Request) string {
 	sessionId := r.Context().Value(SessionIdKey)
 	if sessionId == nil {
-		log.Warn().Msg("SessionId not present in context")
+		requestLogger(r.Context()).Warn().Msg("SessionId not present in context")
 		sessionId = "" // still replace to not leak the value that will be replaced
 	}
 	if sessionIdStr, ok := sessionId.(string); ok {
 		return sessionIdStr
 	} else {
-		log.Warn().Msgf("sessionId is stored, but not a string: %v, will return empty string", sessionId)
+		requestLogger(r.Context()).Warn().Msgf("sessionId is stored, but not a string: %v, will return empty string", sessionId)
 		return ""
 	}
 }
@@ -2,14 +2,20 @@ package server_test
 
 import (
 	"context"
+	"errors"
 	"github.com/ngergs/websrv/v3/server"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"testing/iotest"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -69,6 +75,26 @@ func TestCspHeaderReplaceSessionIdMissing(t *testing.T) {
 	require.Equal(t, "test456", result.Header.Get(server.CspHeaderName))
 }
 
+func TestCspFileReplaceConcurrentLoad(t *testing.T) {
+	var loadCount atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if loadCount.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		_, err := w.Write([]byte(nextHandlerResponse))
+		assert.NoError(t, err)
+	})
+	handler := server.NewCspFileHandler(next, variableName, map[string]string{".js": "application/javascript"})
+
+	responses := serveConcurrently(handler, path, started, release)
+	require.Equal(t, int32(1), loadCount.Load())
+	for _, w := range responses {
+		requireReplacedWith(t, "", w.Body.String())
+	}
+}
+
 func requireReplacedWith(t *testing.T, replacedWithExpectation string, replaced string) {
 	originalReplaced := strings.ReplaceAll(nextHandlerResponse, variableName, replacedWithExpectation)
 	require.Equal(t, originalReplaced, replaced)
@@ -101,3 +127,30 @@ func getMockedCspHeaderHandler() (handler http.Handler, w *httptest.ResponseReco
 
 	return handler, w, r
 }
+
+func TestCspFileReplaceRenderErrorMetric(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registration, err := server.AccessMetricsRegister(registry, metricsNamespace)
+	require.NoError(t, err)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		readerFrom, ok := w.(io.ReaderFrom)
+		require.True(t, ok)
+		_, err := readerFrom.ReadFrom(iotest.ErrReader(errors.New("read error")))
+		assert.Error(t, err)
+	})
+	handler := server.CspFileReplaceWithMetrics(variableName, map[string]string{}, registration)(next)
+
+	w := &readerFromRecorder{httptest.NewRecorder()}
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+path, nil))
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.Equal(t, 1.0, getCounterValue(t, registry, metricsNamespace+"_template_errors", map[string]string{server.TemplateLabel: "/" + path}))
+}
+
+// readerFromRecorder is a httptest.ResponseRecorder that implements io.ReaderFrom like the http.ResponseWriter of the http.Server
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (recorder *readerFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	return io.Copy(recorder.ResponseRecorder, src)
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+)
+
+// EarlyHintsHandler sends the Link HTTP-Header values from all rules matching the request path as HTTP 103 Early Hints
+// to GET requests before the final response is prepared, so that browsers can start to preload even earlier.
+// Browsers only use Early Hints over HTTP/2 and later, HTTP/1.x requests are skipped as older clients may not handle them.
+// The hinted Link HTTP-Headers are not kept for the final response, combine it with the LinkHeaderHandler for this.
+// Link HTTP-Headers that have been set before, e.g. via the static headers, are kept.
+func EarlyHintsHandler(next http.Handler, rules []LinkRule) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.ProtoMajor < 2 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		links := matchingLinks(rules, r.URL.Path)
+		if len(links) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		previous := slices.Clone(w.Header().Values("Link"))
+		for _, link := range links {
+			w.Header().Add("Link", link)
+		}
+		w.WriteHeader(http.StatusEarlyHints)
+		// the informational response has been written, the final response gets its own Link HTTP-Headers
+		if previous == nil {
+			w.Header().Del("Link")
+		} else {
+			w.Header()["Link"] = previous
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,83 @@
+package server_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"testing"
+
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEarlyHints(t *testing.T) {
+	srv := getEarlyHintsServer(true, nil)
+	defer srv.Close()
+
+	hints, resp := getEarlyHintsResponse(t, srv)
+	require.Equal(t, []string{"</main.js>; rel=preload; as=script", "<https://fonts.example.com>; rel=preconnect"}, hints)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "HTTP/2.0", resp.Proto)
+	// the final response has the links only once from the LinkHeaderHandler
+	require.Equal(t, []string{"</main.js>; rel=preload; as=script", "<https://fonts.example.com>; rel=preconnect"}, resp.Header.Values("Link"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, dummyResponse, string(body))
+}
+
+func TestEarlyHintsSkipHttp1(t *testing.T) {
+	srv := getEarlyHintsServer(false, nil)
+	defer srv.Close()
+
+	hints, resp := getEarlyHintsResponse(t, srv)
+	require.Empty(t, hints)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "HTTP/1.1", resp.Proto)
+	require.Len(t, resp.Header.Values("Link"), 2)
+}
+
+func TestEarlyHintsKeepStaticLinks(t *testing.T) {
+	srv := getEarlyHintsServer(true, map[string]string{"Link": "</style.css>; rel=preload; as=style"})
+	defer srv.Close()
+
+	hints, resp := getEarlyHintsResponse(t, srv)
+	require.Equal(t, []string{"</style.css>; rel=preload; as=style", "</main.js>; rel=preload; as=script", "<https://fonts.example.com>; rel=preconnect"}, hints)
+	require.Equal(t, []string{"</style.css>; rel=preload; as=style", "</main.js>; rel=preload; as=script", "<https://fonts.example.com>; rel=preconnect"},
+		resp.Header.Values("Link"))
+}
+
+// getEarlyHintsServer returns a TLS test server that serves an HTML document with the static headers, early hints and Link HTTP-Headers
+func getEarlyHintsServer(http2 bool, headers map[string]string) *httptest.Server {
+	handler := server.EarlyHintsHandler(server.LinkHeaderHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(dummyResponse))
+	}), linkRules), linkRules)
+	srv := httptest.NewUnstartedServer(&server.HeaderHandler{Next: handler, Headers: headers})
+	srv.EnableHTTP2 = http2
+	srv.StartTLS()
+	return srv
+}
+
+// getEarlyHintsResponse requests the root path and returns the Link HTTP-Headers of the HTTP 103 Early Hints and the final response
+func getEarlyHintsResponse(t *testing.T, srv *httptest.Server) ([]string, *http.Response) {
+	var hints []string
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				hints = append(hints, header.Values("Link")...)
+			}
+			return nil
+		},
+	}
+	r, err := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, srv.URL+"/", nil)
+	require.NoError(t, err)
+	resp, err := srv.Client().Do(r)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, resp.Body.Close())
+	})
+	return hints, resp
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// EmptyFileHandler responds with HTTP 204 without a body to GET requests for zero-byte files in the filesystem.
+// HEAD requests and responses served via a FallbackHandler further up the handler chain are passed to the next handler.
+func EmptyFileHandler(next http.Handler, fsys fs.FS) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || isFallback(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if name == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		info, err := fs.Stat(fsys, name)
+		if err != nil || !info.Mode().IsRegular() || info.Size() != 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
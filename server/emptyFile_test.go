@@ -0,0 +1,49 @@
+package server_test
+
+import (
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEmptyFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"empty.txt": {Data: []byte{}},
+		"file.txt":  {Data: []byte(dummyResponse)},
+	}
+	fileServer := http.FileServer(http.FS(fsys))
+	for _, tc := range []struct {
+		name    string
+		enabled bool
+		method  string
+		path    string
+		status  int
+	}{
+		{name: "enabled", enabled: true, method: http.MethodGet, path: "/empty.txt", status: http.StatusNoContent},
+		{name: "disabled", enabled: false, method: http.MethodGet, path: "/empty.txt", status: http.StatusOK},
+		{name: "head", enabled: true, method: http.MethodHead, path: "/empty.txt", status: http.StatusOK},
+		{name: "non-empty", enabled: true, method: http.MethodGet, path: "/file.txt", status: http.StatusOK},
+		{name: "missing", enabled: true, method: http.MethodGet, path: "/missing.txt", status: http.StatusNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := server.Optional(server.EmptyFile(fsys), tc.enabled)(fileServer)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+			require.Equal(t, tc.status, w.Code)
+			if tc.status == http.StatusNoContent {
+				require.Empty(t, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestEmptyFileFallback(t *testing.T) {
+	fsys := fstest.MapFS{"empty.txt": {Data: []byte{}}}
+	handler := server.FallbackHandler(server.EmptyFileHandler(http.FileServer(http.FS(fsys)), fsys), "/empty.txt", http.StatusNotFound)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing.txt", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+}
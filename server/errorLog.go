@@ -0,0 +1,71 @@
+package server
+
+import (
+	stdlog "log"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+var ReasonLabel = "reason"
+
+const tlsHandshakeErrorPrefix = "http: TLS handshake error from "
+
+// tlsHandshakeReasons maps substrings of TLS handshake errors to coarse categories, the first match applies
+var tlsHandshakeReasons = []struct {
+	substring string
+	reason    string
+}{
+	{"does not look like a TLS handshake", "not_tls"},
+	{"certificate", "certificate"},
+	{"cipher", "cipher"},
+	{"version", "version"},
+	{"timeout", "timeout"},
+	{"EOF", "eof"},
+	{"connection reset", "eof"},
+}
+
+// errorLogWriter routes the messages of the http.Server ErrorLog to zerolog and counts TLS handshake failures
+type errorLogWriter struct {
+	registration *PrometheusRegistration
+}
+
+// NewErrorLog returns a logger for the http.Server ErrorLog that routes the messages to zerolog.
+// TLS handshake failures are logged with their coarse reason, configuration mismatches like unsupported certificates,
+// cipher suites or versions on the warn level and connection failures like from port scans on the debug level.
+// If the registration has been prepared with the AccessMetricsOptions.TLS option, the handshake failures are also counted per reason.
+func NewErrorLog(registration *PrometheusRegistration) *stdlog.Logger {
+	return stdlog.New(&errorLogWriter{registration: registration}, "", 0)
+}
+
+func (writer *errorLogWriter) Write(p []byte) (int, error) {
+	message := strings.TrimSuffix(string(p), "\n")
+	handshakeError, ok := strings.CutPrefix(message, tlsHandshakeErrorPrefix)
+	if !ok {
+		log.Error().Msg(message)
+		return len(p), nil
+	}
+	reason := tlsHandshakeReason(handshakeError)
+	if writer.registration != nil && writer.registration.tlsHandshakeFailures != nil {
+		writer.registration.tlsHandshakeFailures.With(map[string]string{ReasonLabel: reason}).Inc()
+	}
+	level := zerolog.DebugLevel
+	if reason == "certificate" || reason == "cipher" || reason == "version" {
+		level = zerolog.WarnLevel
+	}
+	log.WithLevel(level).Str(ReasonLabel, reason).Msg(message)
+	return len(p), nil
+}
+
+// tlsHandshakeReason returns the coarse category of the TLS handshake error
+func tlsHandshakeReason(handshakeError string) string {
+	// skip the remote address
+	_, handshakeError, _ = strings.Cut(handshakeError, ": ")
+	for _, candidate := range tlsHandshakeReasons {
+		if strings.Contains(handshakeError, candidate.substring) {
+			return candidate.reason
+		}
+	}
+	return "other"
+}
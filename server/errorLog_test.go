@@ -0,0 +1,70 @@
+package server_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorLogTlsHandshakeReasons(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registration, err := server.AccessMetricsRegisterWithOptions(registry, metricsNamespace, server.AccessMetricsOptions{TLS: true})
+	require.NoError(t, err)
+	errorLog := server.NewErrorLog(registration)
+
+	errorLog.Print("http: TLS handshake error from 127.0.0.1:1234: remote error: tls: bad certificate")
+	errorLog.Print("http: TLS handshake error from 127.0.0.1:1234: tls: no cipher suite supported by both client and server")
+	errorLog.Print("http: TLS handshake error from 127.0.0.1:1234: tls: client offered only unsupported versions: [301]")
+	errorLog.Print("http: TLS handshake error from 127.0.0.1:1234: EOF")
+	errorLog.Print("http: TLS handshake error from 127.0.0.1:1234: read tcp 127.0.0.1:443->127.0.0.1:1234: i/o timeout")
+	errorLog.Print("http: TLS handshake error from 127.0.0.1:1234: something unexpected")
+	errorLog.Print("http: Accept error: accept tcp: too many open files")
+
+	for _, reason := range []string{"certificate", "cipher", "version", "eof", "timeout", "other"} {
+		require.InDelta(t, 1, getCounterValue(t, registry, metricsNamespace+"_tls_handshake_failures", map[string]string{server.ReasonLabel: reason}), 0, reason)
+	}
+}
+
+func TestErrorLogTlsServer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registration, err := server.AccessMetricsRegisterWithOptions(registry, metricsNamespace, server.AccessMetricsOptions{TLS: true})
+	require.NoError(t, err)
+	srv := httptest.NewUnstartedServer(getStaticHandler(dummyResponse))
+	srv.Config.ErrorLog = server.NewErrorLog(registration)
+	srv.StartTLS()
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+	// neither a TLS handshake nor a plain HTTP request, the latter would be answered with an HTTP 400 without logging
+	_, err = conn.Write([]byte("hello websrv\n"))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	require.Eventually(t, func() bool {
+		return getCounterValue(t, registry, metricsNamespace+"_tls_handshake_failures", map[string]string{server.ReasonLabel: "not_tls"}) == 1
+	}, time.Second, 10*time.Millisecond)
+	resp, err := srv.Client().Get(srv.URL)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestErrorLogTlsDisabled(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registration, err := server.AccessMetricsRegister(registry, metricsNamespace)
+	require.NoError(t, err)
+	server.NewErrorLog(registration).Print("http: TLS handshake error from 127.0.0.1:1234: EOF")
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		require.NotEqual(t, metricsNamespace+"_tls_handshake_failures", family.GetName())
+	}
+}
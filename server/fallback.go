@@ -1,28 +1,172 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/felixge/httpsnoop"
 	"github.com/ngergs/websrv/v3/internal/utils"
 	"io"
+	"io/fs"
 	"net/http"
+	"path"
+	"strings"
 )
 
-// FallbackHandler routes the request to a fallback route on of the given HTTP fallback status codes
+var (
+	ErrFallbackNotFound    = errors.New("fallback file not found")
+	ErrFallbackIsDirectory = errors.New("fallback path is a directory without an index.html")
+	ErrFallbackRedirected  = errors.New("fallback path is redirected by the file server")
+)
+
+// DefaultFallbackCacheControl is the Cache-Control HTTP-Header value for the fallback file.
+// The fallback has to be revalidated, e.g. to pick up the new asset references of a SPA index.html after a deployment.
+const DefaultFallbackCacheControl = "no-cache"
+
+// fallbackDecisionKey is the ContextKey under which the FallbackHandler reports whether the fallback has been served
+var fallbackDecisionKey = &ContextKey{val: "fallbackDecision"}
+
+// fallbackDecision is used by the FallbackHandler to report to outer handlers whether the fallback has been served.
+type fallbackDecision struct {
+	decided  bool
+	fallback bool
+}
+
+// withFallbackDecision adds a fallbackDecision to the request context where the FallbackHandler will report its decision.
+// An already present fallbackDecision is reused.
+func withFallbackDecision(r *http.Request) (*http.Request, *fallbackDecision) {
+	if decision, ok := r.Context().Value(fallbackDecisionKey).(*fallbackDecision); ok {
+		return r, decision
+	}
+	decision := &fallbackDecision{}
+	return r.WithContext(context.WithValue(r.Context(), fallbackDecisionKey, decision)), decision
+}
+
+// reportFallbackDecision stores the decision in the fallbackDecision from the context if present.
+func reportFallbackDecision(ctx context.Context, fallback bool) {
+	if decision, ok := ctx.Value(fallbackDecisionKey).(*fallbackDecision); ok {
+		decision.decided = true
+		decision.fallback = fallback
+	}
+}
+
+// isFallback returns whether a FallbackHandler further up the handler chain serves the fallback for this request.
+func isFallback(r *http.Request) bool {
+	decision, ok := r.Context().Value(fallbackDecisionKey).(*fallbackDecision)
+	return ok && decision.fallback
+}
+
+// ValidateFallback checks that the fallbackPath can be served as file from the filesystem.
+// Directories are only valid if they contain an index.html, as a directory listing would be served otherwise.
+// The file server redirects paths ending in /index.html and directories without a trailing slash, e.g. /app instead of /app/,
+// so these are rejected as the fallback would respond with the redirect.
+func ValidateFallback(fsys fs.FS, fallbackPath string) error {
+	cleaned := path.Clean("/" + fallbackPath)
+	name := strings.TrimPrefix(cleaned, "/")
+	if name == "" {
+		name = "."
+	}
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrFallbackNotFound, fallbackPath, err)
+	}
+	trailingSlash := strings.HasSuffix(fallbackPath, "/")
+	if path.Base(cleaned) == "index.html" || (cleaned != "/" && info.IsDir() != trailingSlash) {
+		return fmt.Errorf("%w: %s", ErrFallbackRedirected, fallbackPath)
+	}
+	if !info.IsDir() {
+		return nil
+	}
+	info, err = fs.Stat(fsys, path.Join(name, "index.html"))
+	if err != nil || info.IsDir() {
+		return fmt.Errorf("%w: %s", ErrFallbackIsDirectory, fallbackPath)
+	}
+	return nil
+}
+
+// FallbackHandler routes the request to a fallback route on of the given HTTP fallback status codes.
+// The fallback response uses the DefaultFallbackCacheControl.
 func FallbackHandler(next http.Handler, fallbackPath string, fallbackCodes ...int) http.Handler {
+	return FallbackHandlerWithCacheControl(next, fallbackPath, DefaultFallbackCacheControl, fallbackCodes...)
+}
+
+// FallbackHandlerWithCacheControl routes the request to a fallback route on of the given HTTP fallback status codes.
+// The Cache-Control HTTP-Header of the fallback response is set to cacheControl, independent of the policy for directly served files.
+// An empty cacheControl keeps the Cache-Control HTTP-Header as set by the other handlers.
+func FallbackHandlerWithCacheControl(next http.Handler, fallbackPath string, cacheControl string, fallbackCodes ...int) http.Handler {
+	return FallbackHandlerWithOptions(next, fallbackPath, FallbackOptions{CacheControl: cacheControl}, fallbackCodes...)
+}
+
+// FallbackOptions holds the options for the FallbackHandlerWithOptions.
+type FallbackOptions struct {
+	// CacheControl is the Cache-Control HTTP-Header of the fallback response. Empty keeps the header as set by the other handlers.
+	CacheControl string
+	// SkipFiles responds with the original status code instead of the fallback for paths that look like files,
+	// i.e. whose last segment has a file extension or is a dotfile like /.env.
+	SkipFiles bool
+}
+
+// FallbackHandlerWithOptions routes the request to a fallback route on of the given HTTP fallback status codes.
+func FallbackHandlerWithOptions(next http.Handler, fallbackPath string, opts FallbackOptions, fallbackCodes ...int) http.Handler {
+	cacheControl := opts.CacheControl
+	fallbackHandler := interceptStatus(next, fallbackCodes, func(w http.ResponseWriter, r *http.Request, status int) {
+		if r.URL.Path == fallbackPath || (opts.SkipFiles && looksLikeFile(r.URL.Path)) {
+			// either the fallback itself failed or it does not apply, the original response has been discarded
+			Error(w, r, "", status)
+			return
+		}
+		reportFallbackDecision(r.Context(), true)
+		r.URL.Path = fallbackPath
+		w.Header().Del("Content-Type")
+		if cacheControl == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(wrapWriteHeader(w, func(_ int) {
+			w.Header().Set("Cache-Control", cacheControl)
+		}), r)
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// makes the decision also available to the handlers further down the chain
+		r, _ = withFallbackDecision(r)
+		reportFallbackDecision(r.Context(), false)
+		fallbackHandler.ServeHTTP(w, r)
+	})
+}
+
+// looksLikeFile returns whether the last segment of the url path has a file extension or is a dotfile.
+// Dots in the directory segments like /a.b/c and a trailing dot like /file. do not count as extension.
+func looksLikeFile(urlPath string) bool {
+	if strings.HasSuffix(urlPath, "/") {
+		return false
+	}
+	segment := path.Base(urlPath)
+	if segment == "." || segment == ".." {
+		return false
+	}
+	if strings.HasPrefix(segment, ".") {
+		return true
+	}
+	return len(path.Ext(segment)) > 1
+}
+
+// interceptStatus discards the response from the next handler if its status code is one of the given codes
+// and calls onIntercept with the original status code to respond instead.
+func interceptStatus(next http.Handler, codes []int, onIntercept func(w http.ResponseWriter, r *http.Request, status int)) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		status := 200
 		wrappedW := httpsnoop.Wrap(w, httpsnoop.Hooks{
 			WriteHeader: func(headerFunc httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
 				return func(code int) {
 					status = code
-					if !utils.Contains(fallbackCodes, code) {
+					if !utils.Contains(codes, code) {
 						headerFunc(code)
 					}
 				}
 			},
 			Write: func(writeFunc httpsnoop.WriteFunc) httpsnoop.WriteFunc {
 				return func(b []byte) (int, error) {
-					if utils.Contains(fallbackCodes, status) {
+					if utils.Contains(codes, status) {
 						// dummy to avoid setting Content-Length here
 						return len(b), nil
 					}
@@ -31,7 +175,7 @@ func FallbackHandler(next http.Handler, fallbackPath string, fallbackCodes ...in
 			},
 			ReadFrom: func(fromFunc httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
 				return func(src io.Reader) (int64, error) {
-					if utils.Contains(fallbackCodes, status) {
+					if utils.Contains(codes, status) {
 						// dummy to avoid setting Content-Length here
 						b, err := io.ReadAll(src)
 						return int64(len(b)), err
@@ -41,10 +185,8 @@ func FallbackHandler(next http.Handler, fallbackPath string, fallbackCodes ...in
 			},
 		})
 		next.ServeHTTP(wrappedW, r)
-		if utils.Contains(fallbackCodes, status) && r.URL.Path != fallbackPath {
-			r.URL.Path = fallbackPath
-			w.Header().Del("Content-Type")
-			next.ServeHTTP(w, r)
+		if utils.Contains(codes, status) {
+			onIntercept(w, r, status)
 		}
 	})
 }
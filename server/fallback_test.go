@@ -6,8 +6,10 @@ import (
 	"github.com/stretchr/testify/require"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
+	"testing/fstest"
 )
 
 const dummyResponse = "hi"
@@ -67,3 +69,92 @@ func TestFallback(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, fallbackResponse, string(response))
 }
+
+func TestValidateFallback(t *testing.T) {
+	fs := fstest.MapFS{
+		"index.html":            {Data: []byte(fallbackResponse)},
+		"app/index.html":        {Data: []byte(fallbackResponse)},
+		"assets/logo.png":       {Data: []byte{}},
+		"nested/index.html/a.b": {Data: []byte{}},
+	}
+	require.NoError(t, server.ValidateFallback(fs, "/"))
+	require.NoError(t, server.ValidateFallback(fs, "/app/"))
+	require.NoError(t, server.ValidateFallback(fs, "/assets/logo.png"))
+	require.ErrorIs(t, server.ValidateFallback(fs, "/index.html"), server.ErrFallbackRedirected)
+	require.ErrorIs(t, server.ValidateFallback(fs, "/app"), server.ErrFallbackRedirected)
+	require.ErrorIs(t, server.ValidateFallback(fs, "/app/index.html"), server.ErrFallbackRedirected)
+	require.ErrorIs(t, server.ValidateFallback(fs, "/assets/logo.png/"), server.ErrFallbackRedirected)
+	require.ErrorIs(t, server.ValidateFallback(fs, "/assets/"), server.ErrFallbackIsDirectory)
+	require.ErrorIs(t, server.ValidateFallback(fs, "/nested/"), server.ErrFallbackIsDirectory)
+	require.ErrorIs(t, server.ValidateFallback(fs, "/missing.html"), server.ErrFallbackNotFound)
+}
+
+func TestFallbackCacheControl(t *testing.T) {
+	handler := server.FallbackHandler(getCacheControlFallbackNext(), fallbackPath, fallbackStatus)
+	require.Equal(t, server.ImmutableCacheControl, getFallbackCacheControl(t, handler, "/main.js"))
+	require.Equal(t, server.DefaultFallbackCacheControl, getFallbackCacheControl(t, handler, "/route"))
+}
+
+func TestFallbackCacheControlCustom(t *testing.T) {
+	handler := server.FallbackHandlerWithCacheControl(getCacheControlFallbackNext(), fallbackPath, "no-store", fallbackStatus)
+	require.Equal(t, "no-store", getFallbackCacheControl(t, handler, "/route"))
+
+	handler = server.FallbackHandlerWithCacheControl(getCacheControlFallbackNext(), fallbackPath, "", fallbackStatus)
+	require.Equal(t, server.ImmutableCacheControl, getFallbackCacheControl(t, handler, "/route"))
+}
+
+func TestFallbackCacheControlWithCacheBustingQuery(t *testing.T) {
+	handler := server.CacheBustingQueryHandler(server.FallbackHandler(getCacheControlFallbackNext(), fallbackPath, fallbackStatus), "v")
+	require.Equal(t, server.ImmutableCacheControl, getFallbackCacheControl(t, handler, "/main.js?v=1"))
+	require.Equal(t, server.DefaultFallbackCacheControl, getFallbackCacheControl(t, handler, "/route?v=1"))
+}
+
+// getCacheControlFallbackNext returns a handler that marks all responses as immutable and only knows /main.js and the fallbackPath.
+func getCacheControlFallbackNext() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", server.ImmutableCacheControl)
+		if r.URL.Path != fallbackPath && r.URL.Path != "/main.js" {
+			w.WriteHeader(fallbackStatus)
+			return
+		}
+		_, _ = w.Write([]byte(dummyResponse))
+	})
+}
+
+// getFallbackCacheControl returns the Cache-Control HTTP-Header of the response for the target
+func getFallbackCacheControl(t *testing.T, handler http.Handler, target string) string {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	return w.Header().Get("Cache-Control")
+}
+
+func TestFallbackSkipFiles(t *testing.T) {
+	tests := []struct {
+		path     string
+		fallback bool
+	}{
+		{path: "/route", fallback: true},
+		{path: "/route/", fallback: true},
+		{path: "/a.b/c", fallback: true},
+		{path: "/.well-known/x", fallback: true},
+		{path: "/file.", fallback: true},
+		{path: "/missing.js", fallback: false},
+		{path: "/a.b/c.d", fallback: false},
+		{path: "/.env", fallback: false},
+		{path: "/dir/.env.local", fallback: false},
+	}
+	handler := server.FallbackHandlerWithOptions(getCacheControlFallbackNext(), fallbackPath, server.FallbackOptions{SkipFiles: true}, fallbackStatus)
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+			if test.fallback {
+				require.Equal(t, http.StatusOK, w.Code)
+				require.Equal(t, dummyResponse, w.Body.String())
+			} else {
+				require.Equal(t, fallbackStatus, w.Code)
+			}
+		})
+	}
+}
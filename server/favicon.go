@@ -0,0 +1,29 @@
+package server
+
+import (
+	"net/http"
+)
+
+// FaviconPath is the path browsers request the favicon from
+const FaviconPath = "/favicon.ico"
+
+// FaviconHandler serves the file under fallbackPath if the next handler responds with HTTP 404 for the FaviconPath.
+// If fallbackPath is empty, HTTP 204 is returned instead. An existing favicon file is served as usual.
+func FaviconHandler(next http.Handler, fallbackPath string) http.Handler {
+	faviconHandler := interceptStatus(next, []int{http.StatusNotFound}, func(w http.ResponseWriter, r *http.Request, _ int) {
+		w.Header().Del("Content-Type")
+		if fallbackPath == "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		r.URL.Path = fallbackPath
+		next.ServeHTTP(w, r)
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != FaviconPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+		faviconHandler.ServeHTTP(w, r)
+	})
+}
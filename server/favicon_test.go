@@ -0,0 +1,46 @@
+package server_test
+
+import (
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+const faviconFallbackPath = "/static/icon.ico"
+
+func TestFaviconFallback(t *testing.T) {
+	result := getFaviconResponse(t, fstest.MapFS{"static/icon.ico": {Data: []byte("fallback")}}, faviconFallbackPath)
+	require.Equal(t, http.StatusOK, result.StatusCode)
+	require.Equal(t, "fallback", string(getReceivedData(t, result.Body)))
+}
+
+func TestFaviconNoContent(t *testing.T) {
+	result := getFaviconResponse(t, fstest.MapFS{}, "")
+	require.Equal(t, http.StatusNoContent, result.StatusCode)
+	require.Empty(t, getReceivedData(t, result.Body))
+}
+
+func TestFaviconPresent(t *testing.T) {
+	result := getFaviconResponse(t, fstest.MapFS{
+		"favicon.ico":     {Data: []byte("favicon")},
+		"static/icon.ico": {Data: []byte("fallback")},
+	}, faviconFallbackPath)
+	require.Equal(t, http.StatusOK, result.StatusCode)
+	require.Equal(t, "favicon", string(getReceivedData(t, result.Body)))
+}
+
+func getFaviconResponse(t *testing.T, fs fstest.MapFS, fallbackPath string) *http.Response {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, server.FaviconPath, nil)
+	handler := server.FaviconHandler(http.FileServer(http.FS(fs)), fallbackPath)
+	handler.ServeHTTP(w, r)
+	result := w.Result()
+	t.Cleanup(func() {
+		err := result.Body.Close()
+		require.NoError(t, err)
+	})
+	return result
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"github.com/ngergs/websrv/v3/filesystem"
+	"io/fs"
+	"net/http"
+)
+
+// FileServer is like http.FileServer, but passes the request context to the filesystem if it implements filesystem.ContextFS.
+// The file access is then cancelled when the client disconnects or the request times out.
+// The request path is reported to the access log as served path, see ReportServedPath.
+func FileServer(fsys fs.FS) http.Handler {
+	if _, ok := fsys.(filesystem.ContextFS); !ok {
+		fileServer := http.FileServer(http.FS(fsys))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ReportServedPath(r.Context(), r.URL.Path)
+			fileServer.ServeHTTP(w, r)
+		})
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ReportServedPath(r.Context(), r.URL.Path)
+		http.FileServer(http.FS(filesystem.WithContext(r.Context(), fsys))).ServeHTTP(w, r)
+	})
+}
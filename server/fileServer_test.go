@@ -0,0 +1,53 @@
+package server_test
+
+import (
+	"context"
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+// contextFs is a filesystem.ContextFS that fails once the context is done
+type contextFs struct {
+	fstest.MapFS
+	contexts []context.Context
+}
+
+func (fsys *contextFs) OpenContext(ctx context.Context, name string) (fs.File, error) {
+	fsys.contexts = append(fsys.contexts, ctx)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return fsys.Open(name)
+}
+
+func TestFileServerContext(t *testing.T) {
+	fsys := &contextFs{MapFS: fstest.MapFS{"file.txt": {Data: []byte(dummyResponse)}}}
+	handler := server.FileServer(fsys)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, dummyResponse, w.Body.String())
+	require.NotEmpty(t, fsys.contexts)
+	require.Equal(t, r.Context(), fsys.contexts[0])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/file.txt", nil).WithContext(ctx))
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestFileServerWithoutContext(t *testing.T) {
+	handler := server.FileServer(fstest.MapFS{"file.txt": {Data: []byte(dummyResponse)}})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/file.txt", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, dummyResponse, w.Body.String())
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// FixedResponse is an inline response for an exact request path.
+type FixedResponse struct {
+	// Status is the HTTP status code of the response
+	Status int
+	// ContentType is the Content-Type HTTP-Header of the response
+	ContentType string
+	// Body is the response body
+	Body string
+	// Override serves the fixed response even if a file exists under the path
+	Override bool
+}
+
+// FixedResponseHandler serves the fixed responses for the exact request paths that are keys of the responses map.
+// A file in the filesystem under the same path takes precedence, unless the FixedResponse is marked as Override.
+// The body is omitted for HEAD requests.
+func FixedResponseHandler(next http.Handler, fsys fs.FS, responses map[string]FixedResponse) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !response.Override {
+			if info, err := fs.Stat(fsys, strings.TrimPrefix(r.URL.Path, "/")); err == nil && info.Mode().IsRegular() {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		if response.ContentType != "" {
+			w.Header().Set("Content-Type", response.ContentType)
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(response.Body)))
+		w.WriteHeader(response.Status)
+		if r.Method == http.MethodHead {
+			return
+		}
+		// write errors are reported by the WriteErrorHandler
+		_, _ = w.Write([]byte(response.Body))
+	})
+}
@@ -0,0 +1,48 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixedResponse(t *testing.T) {
+	fsys := fstest.MapFS{"security.txt": {Data: []byte("from file")}}
+	responses := map[string]server.FixedResponse{
+		"/health":       {Status: http.StatusOK, ContentType: "text/plain", Body: "ok"},
+		"/security.txt": {Status: http.StatusOK, ContentType: "text/plain", Body: "inline"},
+	}
+	handler := server.FixedResponseHandler(server.FileServer(fsys), fsys, responses)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	require.Equal(t, "ok", w.Body.String())
+
+	// the file takes precedence
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/security.txt", nil))
+	require.Equal(t, "from file", w.Body.String())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/health", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "2", w.Header().Get("Content-Length"))
+	require.Empty(t, w.Body.String())
+}
+
+func TestFixedResponseOverride(t *testing.T) {
+	fsys := fstest.MapFS{"security.txt": {Data: []byte("from file")}}
+	responses := map[string]server.FixedResponse{
+		"/security.txt": {Status: http.StatusGone, Body: "inline", Override: true},
+	}
+	w := httptest.NewRecorder()
+	server.FixedResponseHandler(server.FileServer(fsys), fsys, responses).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/security.txt", nil))
+	require.Equal(t, http.StatusGone, w.Code)
+	require.Equal(t, "inline", w.Body.String())
+}
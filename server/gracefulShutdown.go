@@ -14,6 +14,9 @@ import (
 
 var ServerName = &ContextKey{val: "serverName"}
 
+// how often LogDrainProgress checks whether all requests have been drained
+const drainCheckInterval = time.Duration(50) * time.Millisecond
+
 // Shutdowner are functions that support a Shutdown operation. It is the responsibility of the interface implementer to honor the context deadline.
 type Shutdowner interface {
 	Shutdown(ctx context.Context) error
@@ -51,6 +54,41 @@ func ShutdownAfterWaitGroup(ctx context.Context, wg *sync.WaitGroup, server *htt
 
 }
 
+// LogDrainProgress waits till the ctx is cancelled and then logs the number of in-flight requests in the given interval.
+// Blocks till all requests have been drained or the timeout has passed.
+//
+//nolint:contextcheck // the drain happens after the ctx has already been cancelled
+func LogDrainProgress(ctx context.Context, counter *InFlightCounter, interval time.Duration, timeout time.Duration) {
+	<-ctx.Done()
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	// check more often than we log to stop promptly once drained
+	check := time.NewTicker(min(interval, drainCheckInterval))
+	defer check.Stop()
+	nextLog := time.Now().Add(interval)
+	serverName := ctx.Value(ServerName)
+	if serverName == nil {
+		serverName = "server"
+	}
+	for {
+		select {
+		case <-deadline.C:
+			log.Info().Msgf("%v: Drain deadline reached with %d requests still in-flight", serverName, counter.Count())
+			return
+		case now := <-check.C:
+			count := counter.Count()
+			if count == 0 {
+				log.Info().Msgf("%v: All in-flight requests drained", serverName)
+				return
+			}
+			if !now.Before(nextLog) {
+				log.Info().Msgf("%v: Draining, %d requests still in-flight", serverName, count)
+				nextLog = now.Add(interval)
+			}
+		}
+	}
+}
+
 // logShutdown logs the relevant info for the shutdown and extracts the optional server name from the context
 func logShutdown(ctx context.Context, timeout time.Duration) {
 	serverName := ctx.Value(ServerName)
@@ -2,8 +2,10 @@ package server_test
 
 import (
 	"context"
+	"errors"
 	"github.com/ngergs/websrv/v3/server"
 	"github.com/rs/zerolog/log"
+	"net/http"
 	"sync"
 	"syscall"
 	"testing"
@@ -86,6 +88,22 @@ func TestSigTermCtx(t *testing.T) {
 	require.True(t, isChannelClosed(sigtermCtx.Done()))
 }
 
+func TestSigTermCtxDelay(t *testing.T) {
+	sigtermCtx := server.SigTermCtx(context.Background(), time.Duration(300)*time.Millisecond)
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+	// still serving during the delay
+	require.False(t, isChannelClosed(sigtermCtx.Done()))
+	require.Eventually(t, func() bool { return sigtermCtx.Err() != nil }, time.Second, time.Duration(10)*time.Millisecond)
+}
+
+func TestSigTermCtxDelaySecondSignal(t *testing.T) {
+	sigtermCtx := server.SigTermCtx(context.Background(), time.Hour)
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+	require.False(t, isChannelClosed(sigtermCtx.Done()))
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+	require.True(t, isChannelClosed(sigtermCtx.Done()))
+}
+
 func isChannelClosed(channel <-chan struct{}) bool {
 	select {
 	case <-channel:
@@ -94,3 +112,67 @@ func isChannelClosed(channel <-chan struct{}) bool {
 		return false
 	}
 }
+
+func TestLogDrainProgressStopsWhenDrained(t *testing.T) {
+	var counter server.InFlightCounter
+	w, r, next := getDefaultHandlerMocks()
+	release := make(chan struct{})
+	next.serveHttpFunc = func(_ http.ResponseWriter, _ *http.Request) {
+		<-release
+	}
+	handler := server.InFlightHandler(next, &counter)
+	go handler.ServeHTTP(w, r)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		server.LogDrainProgress(ctx, &counter, time.Duration(10)*time.Millisecond, time.Duration(10)*time.Second)
+		close(done)
+	}()
+	cancel()
+	require.False(t, isChannelClosed(done))
+	close(release)
+	require.True(t, isChannelClosed(done))
+}
+
+func TestShutdownPhases(t *testing.T) {
+	var mutex sync.Mutex
+	var order []string
+	record := func(name string) server.Shutdowner {
+		return server.ShutdownFunc(func(ctx context.Context) error {
+			// later phases must not start before the earlier ones have finished
+			time.Sleep(10 * time.Millisecond)
+			mutex.Lock()
+			defer mutex.Unlock()
+			order = append(order, name)
+			return nil
+		})
+	}
+	var phases server.ShutdownPhases
+	phases.Register(server.ShutdownPhaseLogs, "log", record("log"), time.Second)
+	phases.Register(server.ShutdownPhaseMetrics, "metrics", record("metrics"), time.Second)
+	phases.Register(server.ShutdownPhaseServe, "server", record("server"), time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.NoError(t, phases.Run(ctx))
+	require.Equal(t, []string{"server", "metrics", "log"}, order)
+}
+
+func TestShutdownPhasesError(t *testing.T) {
+	errShutdown := errors.New("shutdown failed")
+	called := false
+	var phases server.ShutdownPhases
+	phases.Register(server.ShutdownPhaseServe, "server", server.ShutdownFunc(func(ctx context.Context) error {
+		return errShutdown
+	}), time.Second)
+	phases.Register(server.ShutdownPhaseLogs, "log", server.ShutdownFunc(func(ctx context.Context) error {
+		called = true
+		return nil
+	}), time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, phases.Run(ctx), errShutdown)
+	require.True(t, called)
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// DefaultHiddenAllow are the path prefixes below which dotfiles are served by default
+var DefaultHiddenAllow = []string{"/.well-known/"}
+
+// HiddenOptions determines which request paths are hidden by the HiddenHandler.
+type HiddenOptions struct {
+	// Dotfiles hides all paths with a segment that starts with a dot like /.env or /.git/config
+	Dotfiles bool
+	// Allow are path prefixes like /.well-known/ below which the dotfile check starts, dotfiles further down are still hidden
+	Allow []string
+	// Patterns hide the matching request paths additionally
+	Patterns []*regexp.Regexp
+}
+
+// HiddenHandler responds with HTTP 404 to requests for hidden paths, so that they are not served even if present in the filesystem.
+func HiddenHandler(next http.Handler, opts HiddenOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if opts.hides(r.URL.Path) {
+			Error(w, r, "", http.StatusNotFound)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hides returns whether the request path is hidden by the options
+func (opts HiddenOptions) hides(requestPath string) bool {
+	return (opts.Dotfiles && hasDotSegment(requestPath, opts.Allow)) || matchesAny(opts.Patterns, requestPath)
+}
+
+// hasDotSegment returns whether a segment of the request path starts with a dot.
+// The segments of the longest matching allowed prefix are not checked.
+func hasDotSegment(requestPath string, allow []string) bool {
+	checked := requestPath
+	for _, prefix := range allow {
+		if strings.HasPrefix(requestPath, prefix) && len(requestPath)-len(prefix) < len(checked) {
+			checked = requestPath[len(prefix):]
+		}
+	}
+	for _, segment := range strings.Split(checked, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny returns whether any of the patterns matches the request path
+func matchesAny(patterns []*regexp.Regexp, requestPath string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(requestPath) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHiddenDotfiles(t *testing.T) {
+	handler := server.HiddenHandler(getStaticHandler(dummyResponse), server.HiddenOptions{Dotfiles: true, Allow: server.DefaultHiddenAllow})
+	tests := []struct {
+		path   string
+		hidden bool
+	}{
+		{path: "/.env", hidden: true},
+		{path: "/.git/config", hidden: true},
+		{path: "/assets/.cache/main.js", hidden: true},
+		{path: "/assets/.htaccess", hidden: true},
+		{path: "/.well-known/acme-challenge/x", hidden: false},
+		{path: "/.well-known/security.txt", hidden: false},
+		{path: "/.well-known/.secret", hidden: true},
+		{path: "/.well-known", hidden: true},
+		{path: "/main.js", hidden: false},
+		{path: "/v1.2/file.min.js", hidden: false},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+			if test.hidden {
+				require.Equal(t, http.StatusNotFound, w.Code)
+			} else {
+				require.Equal(t, http.StatusOK, w.Code)
+			}
+		})
+	}
+}
+
+func TestHiddenPatterns(t *testing.T) {
+	handler := server.HiddenHandler(getStaticHandler(dummyResponse), server.HiddenOptions{Patterns: []*regexp.Regexp{regexp.MustCompile(`\.map$`)}})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/main.js.map", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// dotfiles are only hidden if enabled
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.env", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/puzpuzpuz/xsync"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// FileHits is the number of successful requests for a file.
+type FileHits struct {
+	Path string `json:"path"`
+	Hits int64  `json:"hits"`
+}
+
+// HitCounter counts the successful requests per file since start. The counters are atomic and stored in a concurrent map
+// to avoid contention. At most maxEntries files are tracked, requests for further files are only counted as untracked.
+type HitCounter struct {
+	counts     *xsync.MapOf[string, *atomic.Int64]
+	entries    atomic.Int64
+	untracked  atomic.Int64
+	maxEntries int64
+}
+
+// NewHitCounter returns a HitCounter that tracks at most maxEntries files.
+func NewHitCounter(maxEntries int) *HitCounter {
+	return &HitCounter{
+		counts:     xsync.NewMapOf[*atomic.Int64](),
+		maxEntries: int64(maxEntries),
+	}
+}
+
+// Hit increments the counter for the given path.
+func (counter *HitCounter) Hit(path string) {
+	count, ok := counter.counts.Load(path)
+	if !ok {
+		// the limit may be exceeded slightly by concurrent first hits, which is fine for a memory bound
+		if counter.entries.Load() >= counter.maxEntries {
+			counter.untracked.Add(1)
+			return
+		}
+		var loaded bool
+		count, loaded = counter.counts.LoadOrStore(path, &atomic.Int64{})
+		if !loaded {
+			counter.entries.Add(1)
+		}
+	}
+	count.Add(1)
+}
+
+// Untracked returns the number of hits for files that have not been tracked due to the maxEntries limit.
+func (counter *HitCounter) Untracked() int64 {
+	return counter.untracked.Load()
+}
+
+// Top returns the n most requested files sorted descending by their hits.
+func (counter *HitCounter) Top(n int) []FileHits {
+	hits := make([]FileHits, 0, counter.counts.Size())
+	counter.counts.Range(func(path string, count *atomic.Int64) bool {
+		hits = append(hits, FileHits{Path: path, Hits: count.Load()})
+		return true
+	})
+	slices.SortFunc(hits, func(a FileHits, b FileHits) int {
+		if a.Hits != b.Hits {
+			// descending
+			if a.Hits > b.Hits {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+	return hits[:min(n, len(hits))]
+}
+
+// HitCounterHandler counts the successful (HTTP 2xx and 304) responses per request path.
+// Responses served via a FallbackHandler further down the handler chain are not counted.
+func HitCounterHandler(next http.Handler, counter *HitCounter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// handlers further down the chain like the FallbackHandler may modify the request path
+		requestPath := r.URL.Path
+		r, decision := withFallbackDecision(r)
+		next.ServeHTTP(wrapWriteHeader(w, func(code int) {
+			if (code < http.StatusMultipleChoices || code == http.StatusNotModified) && !decision.fallback {
+				counter.Hit(requestPath)
+			}
+		}), r)
+	})
+}
+
+// TopHitsHandler responds with the top n most requested files as JSON. The query parameter n overrides the number of files.
+func TopHitsHandler(counter *HitCounter, n int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		top := n
+		if query := r.URL.Query().Get("n"); query != "" {
+			parsed, err := strconv.Atoi(query)
+			if err != nil || parsed < 0 {
+				Error(w, r, "invalid query parameter n", http.StatusBadRequest)
+				return
+			}
+			top = parsed
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(struct {
+			Files     []FileHits `json:"files"`
+			Untracked int64      `json:"untracked"`
+		}{Files: counter.Top(top), Untracked: counter.Untracked()})
+		if err != nil {
+			requestLogger(r.Context()).Warn().Err(err).Msg("error writing top hits response")
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package server_test
+
+import (
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestHitCounterTop(t *testing.T) {
+	counter := server.NewHitCounter(10)
+	for path, hits := range map[string]int{"/a.js": 3, "/b.js": 1, "/c.js": 2, "/d.js": 2} {
+		for range hits {
+			counter.Hit(path)
+		}
+	}
+	require.Equal(t, []server.FileHits{{Path: "/a.js", Hits: 3}, {Path: "/c.js", Hits: 2}, {Path: "/d.js", Hits: 2}}, counter.Top(3))
+	require.Len(t, counter.Top(10), 4)
+}
+
+func TestHitCounterMaxEntries(t *testing.T) {
+	counter := server.NewHitCounter(1)
+	counter.Hit("/a.js")
+	counter.Hit("/b.js")
+	counter.Hit("/a.js")
+	require.Equal(t, []server.FileHits{{Path: "/a.js", Hits: 2}}, counter.Top(10))
+	require.Equal(t, int64(1), counter.Untracked())
+}
+
+func TestHitCounterConcurrent(t *testing.T) {
+	counter := server.NewHitCounter(10)
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				counter.Hit("/a.js")
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, []server.FileHits{{Path: "/a.js", Hits: 1000}}, counter.Top(1))
+}
+
+func TestHitCounterHandler(t *testing.T) {
+	counter := server.NewHitCounter(10)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != fallbackPath && r.URL.Path != "/main.js" {
+			w.WriteHeader(fallbackStatus)
+			return
+		}
+		_, _ = w.Write([]byte(dummyResponse))
+	})
+	handler := server.HitCounterHandler(server.FallbackHandler(next, fallbackPath, fallbackStatus), counter)
+	for _, requestPath := range []string{"/main.js", "/main.js", "/route"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, requestPath, nil))
+	}
+	require.Equal(t, []server.FileHits{{Path: "/main.js", Hits: 2}}, counter.Top(10))
+}
+
+func TestTopHitsHandler(t *testing.T) {
+	counter := server.NewHitCounter(10)
+	counter.Hit("/a.js")
+	counter.Hit("/a.js")
+	counter.Hit("/b.js")
+	handler := server.TopHitsHandler(counter, 1)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hits", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"files":[{"path":"/a.js","hits":2}],"untracked":0}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hits?n=5", nil))
+	require.JSONEq(t, `{"files":[{"path":"/a.js","hits":2},{"path":"/b.js","hits":1}],"untracked":0}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hits?n=x", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// HostAllowlistHandler rejects requests whose Host header is not in the allowedHosts with HTTP 421.
+// Requests without a Host header are rejected with HTTP 400.
+// Entries of the form *.example.com allow all subdomains of example.com, but not example.com itself.
+// The comparison ignores the port and case. For accepted requests r.Host is normalized to lower case without a trailing dot.
+// The handler is a no-op if allowedHosts is empty.
+func HostAllowlistHandler(next http.Handler, allowedHosts []string) http.Handler {
+	if len(allowedHosts) == 0 {
+		return next
+	}
+	exact := make(map[string]struct{})
+	var wildcardSuffixes []string
+	for _, host := range allowedHosts {
+		host = normalizeHostname(host)
+		if strings.HasPrefix(host, "*.") {
+			wildcardSuffixes = append(wildcardSuffixes, host[1:])
+		} else {
+			exact[host] = struct{}{}
+		}
+	}
+	isAllowed := func(hostname string) bool {
+		if _, ok := exact[hostname]; ok {
+			return true
+		}
+		for _, suffix := range wildcardSuffixes {
+			if len(hostname) > len(suffix) && strings.HasSuffix(hostname, suffix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host == "" {
+			Error(w, r, "Missing Host header", http.StatusBadRequest)
+			return
+		}
+		hostname, port := splitHostPort(r.Host)
+		hostname = normalizeHostname(hostname)
+		if !isAllowed(hostname) {
+			requestLogger(r.Context()).Debug().Msgf("Rejected request for host %q", r.Host)
+			Error(w, r, "", http.StatusMisdirectedRequest)
+			return
+		}
+		if port != "" {
+			r.Host = net.JoinHostPort(hostname, port)
+		} else {
+			r.Host = hostname
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// splitHostPort splits the host into hostname and port. The port is empty if the host does not contain one.
+func splitHostPort(host string) (hostname string, port string) {
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return strings.Trim(host, "[]"), ""
+	}
+	return hostname, port
+}
+
+// normalizeHostname converts the hostname to lower case and removes a trailing dot.
+func normalizeHostname(hostname string) string {
+	return strings.TrimSuffix(strings.ToLower(hostname), ".")
+}
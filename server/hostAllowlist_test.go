@@ -0,0 +1,48 @@
+package server_test
+
+import (
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHostAllowlist(t *testing.T) {
+	allowedHosts := []string{"Example.com", "*.apps.example.com"}
+	for _, tc := range []struct {
+		host         string
+		status       int
+		receivedHost string
+	}{
+		{host: "example.com", status: http.StatusOK, receivedHost: "example.com"},
+		{host: "EXAMPLE.com.:8080", status: http.StatusOK, receivedHost: "example.com:8080"},
+		{host: "a.apps.example.com", status: http.StatusOK, receivedHost: "a.apps.example.com"},
+		{host: "a.b.apps.example.com", status: http.StatusOK, receivedHost: "a.b.apps.example.com"},
+		{host: "apps.example.com", status: http.StatusMisdirectedRequest},
+		{host: "evilexample.com", status: http.StatusMisdirectedRequest},
+		{host: "a.example.com", status: http.StatusMisdirectedRequest},
+		{host: "", status: http.StatusBadRequest},
+	} {
+		t.Run(tc.host, func(t *testing.T) {
+			var receivedHost string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				receivedHost = r.Host
+			})
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Host = tc.host
+			server.HostAllowlistHandler(next, allowedHosts).ServeHTTP(w, r)
+			require.Equal(t, tc.status, w.Code)
+			require.Equal(t, tc.receivedHost, receivedHost)
+		})
+	}
+}
+
+func TestHostAllowlistEmpty(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Host = "any.host"
+	server.HostAllowlistHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), nil).ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrHstsPreloadNotEligible = errors.New("the HSTS preload list requires a max-age of at least one year and includeSubDomains")
+
+// HstsPreloadMinMaxAge is the minimal max-age that is accepted by the HSTS preload list
+const HstsPreloadMinMaxAge = 365 * 24 * time.Hour
+
+// HstsOptions holds the directives of the Strict-Transport-Security HTTP-Header.
+type HstsOptions struct {
+	// MaxAge is the duration for which browsers only use HTTPS, it is rounded down to seconds
+	MaxAge time.Duration
+	// IncludeSubDomains extends the policy to all subdomains
+	IncludeSubDomains bool
+	// Preload signals consent to be added to the HSTS preload list of the browsers
+	Preload bool
+	// TrustForwardedProto also treats requests as TLS requests if their X-Forwarded-Proto HTTP-Header is https,
+	// e.g. behind a TLS-terminating reverse proxy. Only enable it if the proxy overwrites this header for all requests.
+	TrustForwardedProto bool
+}
+
+// CheckHstsPreload returns ErrHstsPreloadNotEligible if preload is set, but the other directives do not meet the preload list requirements.
+func CheckHstsPreload(opts HstsOptions) error {
+	if opts.Preload && (opts.MaxAge < HstsPreloadMinMaxAge || !opts.IncludeSubDomains) {
+		return ErrHstsPreloadNotEligible
+	}
+	return nil
+}
+
+// HstsHandler adds the Strict-Transport-Security HTTP-Header to responses for requests over a completed TLS connection
+// or with the X-Forwarded-Proto HTTP-Header https if trusted. Browsers ignore the header over plain HTTP,
+// so it is not added there, but a header that has been set elsewhere, e.g. via the static headers, is kept.
+func HstsHandler(next http.Handler, opts HstsOptions) http.Handler {
+	value := "max-age=" + strconv.FormatInt(int64(opts.MaxAge/time.Second), 10)
+	if opts.IncludeSubDomains {
+		value += "; includeSubDomains"
+	}
+	if opts.Preload {
+		value += "; preload"
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.TLS != nil && r.TLS.HandshakeComplete) || (opts.TrustForwardedProto && isForwardedHttps(r)) {
+			w.Header().Set("Strict-Transport-Security", value)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isForwardedHttps returns whether the X-Forwarded-Proto HTTP-Header is https. For chained proxies, the first value applies.
+func isForwardedHttps(r *http.Request) bool {
+	proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+	return strings.EqualFold(strings.TrimSpace(proto), "https")
+}
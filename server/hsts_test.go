@@ -0,0 +1,77 @@
+package server_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHstsTls(t *testing.T) {
+	w, r, next := getDefaultHandlerMocks()
+	r.TLS = &tls.ConnectionState{HandshakeComplete: true}
+	opts := server.HstsOptions{MaxAge: server.HstsPreloadMinMaxAge, IncludeSubDomains: true, Preload: true}
+	server.HstsHandler(next, opts).ServeHTTP(w, r)
+	require.Equal(t, "max-age=31536000; includeSubDomains; preload", w.Header().Get("Strict-Transport-Security"))
+
+	w, r, next = getDefaultHandlerMocks()
+	r.TLS = &tls.ConnectionState{HandshakeComplete: true}
+	server.HstsHandler(next, server.HstsOptions{MaxAge: time.Hour}).ServeHTTP(w, r)
+	require.Equal(t, "max-age=3600", w.Header().Get("Strict-Transport-Security"))
+}
+
+func TestHstsNoTls(t *testing.T) {
+	w, r, next := getDefaultHandlerMocks()
+	server.HstsHandler(next, server.HstsOptions{MaxAge: time.Hour}).ServeHTTP(w, r)
+	require.Empty(t, w.Header().Get("Strict-Transport-Security"))
+
+	w, r, next = getDefaultHandlerMocks()
+	r.TLS = &tls.ConnectionState{HandshakeComplete: false}
+	server.HstsHandler(next, server.HstsOptions{MaxAge: time.Hour}).ServeHTTP(w, r)
+	require.Empty(t, w.Header().Get("Strict-Transport-Security"))
+
+	// the X-Forwarded-Proto HTTP-Header is ignored unless trusted
+	w, r, next = getDefaultHandlerMocks()
+	r.Header.Set("X-Forwarded-Proto", "https")
+	server.HstsHandler(next, server.HstsOptions{MaxAge: time.Hour}).ServeHTTP(w, r)
+	require.Empty(t, w.Header().Get("Strict-Transport-Security"))
+
+	// a static header is kept for plain HTTP
+	w, _, next = getDefaultHandlerMocks()
+	handler := &server.HeaderHandler{
+		Next:    server.HstsHandler(next, server.HstsOptions{MaxAge: time.Hour}),
+		Headers: map[string]string{"Strict-Transport-Security": "max-age=60"},
+	}
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, "max-age=60", w.Header().Get("Strict-Transport-Security"))
+}
+
+func TestHstsTrustForwardedProto(t *testing.T) {
+	opts := server.HstsOptions{MaxAge: time.Hour, TrustForwardedProto: true}
+	for proto, expected := range map[string]string{
+		"https":       "max-age=3600",
+		"HTTPS":       "max-age=3600",
+		"https, http": "max-age=3600",
+		"http":        "",
+		"http, https": "",
+		"":            "",
+	} {
+		w, r, next := getDefaultHandlerMocks()
+		if proto != "" {
+			r.Header.Set("X-Forwarded-Proto", proto)
+		}
+		server.HstsHandler(next, opts).ServeHTTP(w, r)
+		require.Equal(t, expected, w.Header().Get("Strict-Transport-Security"), proto)
+	}
+}
+
+func TestCheckHstsPreload(t *testing.T) {
+	require.NoError(t, server.CheckHstsPreload(server.HstsOptions{MaxAge: time.Hour}))
+	require.NoError(t, server.CheckHstsPreload(server.HstsOptions{MaxAge: server.HstsPreloadMinMaxAge, IncludeSubDomains: true, Preload: true}))
+	require.ErrorIs(t, server.CheckHstsPreload(server.HstsOptions{MaxAge: time.Hour, IncludeSubDomains: true, Preload: true}), server.ErrHstsPreloadNotEligible)
+	require.ErrorIs(t, server.CheckHstsPreload(server.HstsOptions{MaxAge: server.HstsPreloadMinMaxAge, Preload: true}), server.ErrHstsPreloadNotEligible)
+}
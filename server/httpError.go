@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	textMediaType = "text/plain"
+	jsonMediaType = "application/json"
+	htmlMediaType = "text/html"
+)
+
+// errorMediaTypes are the media types of the error responses, the first one is preferred on equal quality values.
+var errorMediaTypes = []string{textMediaType, jsonMediaType, htmlMediaType}
+
+// jsonError is the stable schema of JSON error responses.
+type jsonError struct {
+	Error  string `json:"error"`
+	Status int    `json:"status"`
+}
+
+// Error replies to the request with the given error message and HTTP status code. Similar to http.Error,
+// but the response body is negotiated based on the Accept HTTP-Header of the request.
+// JSON is structured as {"error":"not found","status":404}, HTML as minimal error page and all other requests receive plain text.
+// An empty message defaults to the lower-cased status text.
+func Error(w http.ResponseWriter, r *http.Request, message string, status int) {
+	if message == "" {
+		message = strings.ToLower(http.StatusText(status))
+	}
+	var body string
+	mediaType := negotiateMediaType(r.Header.Get("Accept"), errorMediaTypes)
+	switch mediaType {
+	case jsonMediaType:
+		// marshalling can not fail for the string and int fields
+		data, _ := json.Marshal(jsonError{Error: message, Status: status})
+		body = string(data)
+	case htmlMediaType:
+		title := html.EscapeString(strconv.Itoa(status) + " " + http.StatusText(status))
+		body = fmt.Sprintf("<!DOCTYPE html><html><head><title>%s</title></head><body><h1>%s</h1><p>%s</p></body></html>",
+			title, title, html.EscapeString(message))
+	default:
+		body = message + "\n"
+	}
+	header := w.Header()
+	header.Del("Content-Length")
+	header.Del("Content-Encoding")
+	header.Set("Content-Type", mediaType+"; charset=utf-8")
+	header.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(body))
+}
+
+// NegotiatedErrorHandler replaces the response bodies of the next handler for the given status codes with the
+// Accept-dependent error bodies from Error.
+func NegotiatedErrorHandler(next http.Handler, codes ...int) http.Handler {
+	return interceptStatus(next, codes, func(w http.ResponseWriter, r *http.Request, status int) {
+		Error(w, r, "", status)
+	})
+}
+
+// negotiateMediaType returns the offer with the highest quality value in the Accept HTTP-Header.
+// On equal quality values the earlier offer is preferred. The first offer is returned if none is acceptable.
+func negotiateMediaType(accept string, offers []string) string {
+	if accept == "" {
+		return offers[0]
+	}
+	best := offers[0]
+	bestQuality := 0.0
+	for _, offer := range offers {
+		quality := acceptQuality(accept, offer)
+		if quality > bestQuality {
+			best = offer
+			bestQuality = quality
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the quality value of the most specific media range in the Accept HTTP-Header that matches the offer.
+func acceptQuality(accept string, offer string) float64 {
+	offerType, _, _ := strings.Cut(offer, "/")
+	quality := 0.0
+	specificity := -1
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+		var rangeSpecificity int
+		switch mediaType {
+		case offer:
+			rangeSpecificity = 2
+		case offerType + "/*":
+			rangeSpecificity = 1
+		case "*/*":
+			rangeSpecificity = 0
+		default:
+			continue
+		}
+		if rangeSpecificity <= specificity {
+			continue
+		}
+		specificity = rangeSpecificity
+		quality = 1
+		if q, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+	}
+	return quality
+}
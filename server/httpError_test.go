@@ -0,0 +1,73 @@
+package server_test
+
+import (
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorJson(t *testing.T) {
+	for _, accept := range []string{"application/json", "application/*", "text/html;q=0.5, application/json"} {
+		w := getErrorResponse(accept, "")
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"), accept)
+		require.JSONEq(t, `{"error":"not found","status":404}`, w.Body.String(), accept)
+	}
+}
+
+func TestErrorHtml(t *testing.T) {
+	for _, accept := range []string{"text/html", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"} {
+		w := getErrorResponse(accept, "<missing>")
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"), accept)
+		require.Contains(t, w.Body.String(), "<h1>404 Not Found</h1>", accept)
+		require.Contains(t, w.Body.String(), "&lt;missing&gt;", accept)
+	}
+}
+
+func TestErrorText(t *testing.T) {
+	for _, accept := range []string{"", "*/*", "text/*", "text/plain", "image/png", "application/json;q=0"} {
+		w := getErrorResponse(accept, "")
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"), accept)
+		require.Equal(t, "not found\n", w.Body.String(), accept)
+	}
+}
+
+func TestNegotiatedErrorHandler(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(dummyResponse))
+	})
+	handler := server.NegotiatedErrorHandler(next, http.StatusNotFound)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/missing", nil)
+	r.Header.Set("Accept", "application/json")
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.JSONEq(t, `{"error":"not found","status":404}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "application/json")
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, dummyResponse, w.Body.String())
+}
+
+// getErrorResponse returns the HTTP 404 error response for a request with the given Accept HTTP-Header
+func getErrorResponse(accept string, message string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	server.Error(w, r, message, http.StatusNotFound)
+	return w
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// InFlightCounter keeps track of the number of requests that are currently processed.
+type InFlightCounter struct {
+	count atomic.Int64
+}
+
+// Count returns the number of requests that are currently processed.
+func (counter *InFlightCounter) Count() int64 {
+	return counter.count.Load()
+}
+
+// InFlightHandler increments the counter for the duration of each request.
+func InFlightHandler(next http.Handler, counter *InFlightCounter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter.count.Add(1)
+		defer counter.count.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,19 @@
+package server_test
+
+import (
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestInFlightCounter(t *testing.T) {
+	var counter server.InFlightCounter
+	w, r, next := getDefaultHandlerMocks()
+	next.serveHttpFunc = func(_ http.ResponseWriter, _ *http.Request) {
+		require.Equal(t, int64(1), counter.Count())
+	}
+	handler := server.InFlightHandler(next, &counter)
+	handler.ServeHTTP(w, r)
+	require.Equal(t, int64(0), counter.Count())
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// IndexHandler serves the first of the indexFiles that exists in the filesystem for directory requests (paths with a trailing slash).
+// The request path is rewritten to the index file, so that the media type and caching are determined for it.
+// If none of the indexFiles exist the request is passed unchanged to the next handler.
+func IndexHandler(next http.Handler, fsys fs.FS, indexFiles []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		dir := strings.TrimPrefix(r.URL.Path, "/")
+		for _, indexFile := range indexFiles {
+			info, err := fs.Stat(fsys, path.Join(".", dir, indexFile))
+			if err != nil || info.IsDir() {
+				continue
+			}
+			if indexFile == "index.html" {
+				// the http.FileServer serves index.html for directories itself and redirects direct requests for it
+				next.ServeHTTP(w, r)
+				return
+			}
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.URL = new(url.URL)
+			*r2.URL = *r.URL
+			r2.URL.Path = r.URL.Path + indexFile
+			r2.URL.RawPath = ""
+			next.ServeHTTP(w, r2)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
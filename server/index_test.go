@@ -0,0 +1,50 @@
+package server_test
+
+import (
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestIndex(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":        {Data: []byte("root index.html")},
+		"docs/index.htm":    {Data: []byte("docs index.htm")},
+		"docs/default.html": {Data: []byte("docs default.html")},
+		"blog/default.html": {Data: []byte("blog default.html")},
+	}
+	handler := server.IndexHandler(http.FileServer(http.FS(fsys)), fsys, []string{"index.html", "index.htm", "default.html"})
+	for _, tc := range []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{path: "/", status: http.StatusOK, body: "root index.html"},
+		{path: "/docs/", status: http.StatusOK, body: "docs index.htm"},
+		{path: "/blog/", status: http.StatusOK, body: "blog default.html"},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			require.Equal(t, tc.status, w.Code)
+			require.Equal(t, tc.body, w.Body.String())
+			require.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestIndexNoneFound(t *testing.T) {
+	fsys := fstest.MapFS{"docs/default.html": {Data: []byte("docs default.html")}}
+	var receivedPath string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedPath = r.URL.Path
+		w.WriteHeader(http.StatusNotFound)
+	})
+	w := httptest.NewRecorder()
+	server.IndexHandler(next, fsys, []string{"index.html", "index.htm"}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs/", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Equal(t, "/docs/", receivedPath)
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+)
+
+// latencyPhases holds the points in time at which the final status code and the first body byte have been written.
+type latencyPhases struct {
+	start     time.Time
+	status    time.Time
+	firstByte time.Time
+}
+
+// withLatencyPhases wraps the http.ResponseWriter to record the latencyPhases of the response.
+func withLatencyPhases(w http.ResponseWriter) (http.ResponseWriter, *latencyPhases) {
+	phases := &latencyPhases{start: time.Now()}
+	onStatus := func() {
+		if phases.status.IsZero() {
+			phases.status = time.Now()
+		}
+	}
+	onBody := func() {
+		onStatus()
+		if phases.firstByte.IsZero() {
+			phases.firstByte = time.Now()
+		}
+	}
+	return httpsnoop.Wrap(w, httpsnoop.Hooks{
+		WriteHeader: func(headerFunc httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+			return func(code int) {
+				// informational responses are not final
+				if code >= http.StatusOK {
+					onStatus()
+				}
+				headerFunc(code)
+			}
+		},
+		Write: func(writeFunc httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+			return func(b []byte) (int, error) {
+				if len(b) == 0 {
+					onStatus()
+				} else {
+					onBody()
+				}
+				return writeFunc(b)
+			}
+		},
+		ReadFrom: func(fromFunc httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+			return func(src io.Reader) (int64, error) {
+				onBody()
+				return fromFunc(src)
+			}
+		},
+	}), phases
+}
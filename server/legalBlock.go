@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/http"
+	"regexp"
+)
+
+// LegalBlockRule blocks the request paths that match the Path for legal reasons.
+type LegalBlockRule struct {
+	Path *regexp.Regexp
+	// Authority is the URL of the entity that requested the block, it is referenced in the Link HTTP-Header with rel="blocked-by"
+	Authority string
+	// Notice is the response body, empty defaults to the status text
+	Notice string
+}
+
+// LegalBlockHandler responds with HTTP 451 Unavailable For Legal Reasons according to RFC 7725 for request paths that match one of the rules.
+// The first matching rule applies.
+func LegalBlockHandler(next http.Handler, rules []LegalBlockRule) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, rule := range rules {
+			if !rule.Path.MatchString(r.URL.Path) {
+				continue
+			}
+			if rule.Authority != "" {
+				w.Header().Add("Link", "<"+rule.Authority+">; rel=\"blocked-by\"")
+			}
+			Error(w, r, rule.Notice, http.StatusUnavailableForLegalReasons)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,35 @@
+package server_test
+
+import (
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestLegalBlock(t *testing.T) {
+	rules := []server.LegalBlockRule{
+		{Path: regexp.MustCompile("^/blocked/"), Authority: "https://authority.example.com", Notice: "blocked by court order"},
+		{Path: regexp.MustCompile(`^/other\.html$`)},
+	}
+	handler := server.LegalBlockHandler(getStaticHandler(dummyResponse), rules)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/blocked/file.html", nil))
+	require.Equal(t, http.StatusUnavailableForLegalReasons, w.Code)
+	require.Equal(t, `<https://authority.example.com>; rel="blocked-by"`, w.Header().Get("Link"))
+	require.Equal(t, "blocked by court order\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/other.html", nil))
+	require.Equal(t, http.StatusUnavailableForLegalReasons, w.Code)
+	require.Empty(t, w.Header().Get("Link"))
+	require.Equal(t, "unavailable for legal reasons\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/index.html", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, dummyResponse, w.Body.String())
+}
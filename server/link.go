@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net/http"
+	"regexp"
+)
+
+// LinkRule holds Link HTTP-Header values like `</main.js>; rel=preload; as=script` for the request paths matching the pattern.
+type LinkRule struct {
+	Path  *regexp.Regexp
+	Links []string
+}
+
+// matchingLinks returns the Link HTTP-Header values of all rules that match the request path.
+func matchingLinks(rules []LinkRule, requestPath string) []string {
+	var links []string
+	for _, rule := range rules {
+		if rule.Path.MatchString(requestPath) {
+			links = append(links, rule.Links...)
+		}
+	}
+	return links
+}
+
+// LinkHeaderHandler adds the Link HTTP-Headers from all matching rules to successful HTML responses,
+// so that browsers can start to preload or preconnect early. Responses for other assets are left untouched.
+func LinkHeaderHandler(next http.Handler, rules []LinkRule) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		links := matchingLinks(rules, r.URL.Path)
+		if len(links) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(wrapWriteHeader(w, func(code int) {
+			if code == http.StatusOK && isHtml(code, w.Header()) {
+				for _, link := range links {
+					w.Header().Add("Link", link)
+				}
+			}
+		}), r)
+	})
+}
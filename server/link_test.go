@@ -0,0 +1,45 @@
+package server_test
+
+import (
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+var linkRules = []server.LinkRule{
+	{Path: regexp.MustCompile("^/$"), Links: []string{"</main.js>; rel=preload; as=script"}},
+	{Path: regexp.MustCompile(".*"), Links: []string{"<https://fonts.example.com>; rel=preconnect"}},
+}
+
+func TestLinkHeader(t *testing.T) {
+	result := getLinkHeaderResponse(t, "/", "text/html; charset=utf-8")
+	require.Equal(t, []string{"</main.js>; rel=preload; as=script", "<https://fonts.example.com>; rel=preconnect"}, result.Header.Values("Link"))
+
+	result = getLinkHeaderResponse(t, "/other", "text/html; charset=utf-8")
+	require.Equal(t, []string{"<https://fonts.example.com>; rel=preconnect"}, result.Header.Values("Link"))
+}
+
+func TestLinkHeaderNonHtml(t *testing.T) {
+	result := getLinkHeaderResponse(t, "/", "text/javascript")
+	require.Empty(t, result.Header.Values("Link"))
+}
+
+func getLinkHeaderResponse(t *testing.T, requestPath string, contentType string) *http.Response {
+	w, r, next := getDefaultHandlerMocks()
+	next.serveHttpFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+	}
+	r.URL = &url.URL{Path: requestPath}
+	handler := server.LinkHeaderHandler(next, linkRules)
+	handler.ServeHTTP(w, r)
+	result := w.Result()
+	t.Cleanup(func() {
+		err := result.Body.Close()
+		require.NoError(t, err)
+	})
+	return result
+}
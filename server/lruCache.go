@@ -0,0 +1,61 @@
+package server
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache is a concurrency-safe map that holds at most maxEntries entries and evicts the least recently used entry when full.
+type lruCache[K comparable, V any] struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[K]*list.Element
+	// order holds the lruEntry values, the front is the most recently used
+	order *list.List
+}
+
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+func newLruCache[K comparable, V any](maxEntries int) *lruCache[K, V] {
+	return &lruCache[K, V]{maxEntries: maxEntries, entries: make(map[K]*list.Element), order: list.New()}
+}
+
+// Load returns the value for the key and marks it as recently used.
+func (cache *lruCache[K, V]) Load(key K) (V, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	element, ok := cache.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	cache.order.MoveToFront(element)
+	return element.Value.(*lruEntry[K, V]).value, true
+}
+
+// Store adds or replaces the value for the key. The least recently used entry is evicted if the cache is full.
+func (cache *lruCache[K, V]) Store(key K, value V) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if element, ok := cache.entries[key]; ok {
+		element.Value.(*lruEntry[K, V]).value = value
+		cache.order.MoveToFront(element)
+		return
+	}
+	cache.entries[key] = cache.order.PushFront(&lruEntry[K, V]{key: key, value: value})
+	if cache.order.Len() > cache.maxEntries {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*lruEntry[K, V]).key)
+	}
+}
+
+// Size returns the number of stored entries.
+func (cache *lruCache[K, V]) Size() int {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	return cache.order.Len()
+}
@@ -0,0 +1,19 @@
+package server
+
+import (
+	"net/http"
+)
+
+// DefaultMaxPathLength is a generous limit for the length of request paths.
+const DefaultMaxPathLength = 4096
+
+// MaxPathLengthHandler rejects requests whose path is longer than maxLength bytes with HTTP 414.
+func MaxPathLengthHandler(next http.Handler, maxLength int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.URL.Path) > maxLength {
+			Error(w, r, "", http.StatusRequestURITooLong)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
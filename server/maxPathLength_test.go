@@ -0,0 +1,22 @@
+package server_test
+
+import (
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxPathLength(t *testing.T) {
+	handler := server.MaxPathLengthHandler(getStaticHandler(dummyResponse), 10)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+strings.Repeat("a", 9), nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+strings.Repeat("a", 10), nil))
+	require.Equal(t, http.StatusRequestURITooLong, w.Code)
+}
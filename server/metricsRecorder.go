@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// make sure that the PrometheusRegistration implements the recorder interfaces
+var (
+	_ MetricsRecorder    = &PrometheusRegistration{}
+	_ FallbackRecorder   = &PrometheusRegistration{}
+	_ WriteErrorRecorder = &PrometheusRegistration{}
+)
+
+// MetricsRecorder records the access metrics of a response. It decouples the AccessMetricsRecorderHandler from a
+// specific metrics library, the PrometheusRegistration is one implementation. The methods are called once per response.
+type MetricsRecorder interface {
+	// RecordStatus records the HTTP status code
+	RecordStatus(r *http.Request, status int)
+	// RecordBytes records the number of written body bytes
+	RecordBytes(r *http.Request, bytes int64)
+	// RecordDuration records the time till the response has been written
+	RecordDuration(r *http.Request, duration time.Duration)
+}
+
+// FallbackRecorder is an optional interface for a MetricsRecorder that records whether the fallback file has been served.
+type FallbackRecorder interface {
+	// RecordFallback is only called for successful responses, i.e. 2xx and HTTP 304, if a FallbackHandler is part of the handler chain
+	RecordFallback(r *http.Request, fallback bool)
+}
+
+// WriteErrorRecorder records errors while writing the response body, see WriteErrorHandler.
+type WriteErrorRecorder interface {
+	// RecordWriteError is called once per response with a write error with the reason
+	// WriteErrorClientDisconnect or WriteErrorOther
+	RecordWriteError(r *http.Request, reason string)
+}
+
+// RecordStatus increments the status code counter and the protocol counter if enabled.
+func (registration *PrometheusRegistration) RecordStatus(r *http.Request, status int) {
+	registration.statusCode.With(map[string]string{DomainLabel: r.Host, StatusLabel: strconv.Itoa(status)}).Inc()
+	if registration.protocol != nil {
+		registration.protocol.With(map[string]string{DomainLabel: r.Host, ProtocolLabel: negotiatedProtocol(r)}).Inc()
+	}
+}
+
+// RecordBytes adds the bytes to the egress bytes counter.
+func (registration *PrometheusRegistration) RecordBytes(r *http.Request, bytes int64) {
+	registration.bytesSend.With(map[string]string{DomainLabel: r.Host}).Add(float64(bytes))
+}
+
+// RecordDuration observes the duration in the request duration histogram.
+func (registration *PrometheusRegistration) RecordDuration(r *http.Request, duration time.Duration) {
+	registration.duration.With(map[string]string{DomainLabel: r.Host}).Observe(duration.Seconds())
+}
+
+// RecordFallback increments the file serves counter.
+func (registration *PrometheusRegistration) RecordFallback(r *http.Request, fallback bool) {
+	registration.fileServes.With(map[string]string{DomainLabel: r.Host, FallbackLabel: strconv.FormatBool(fallback)}).Inc()
+}
+
+// RecordWriteError increments the write errors counter.
+func (registration *PrometheusRegistration) RecordWriteError(r *http.Request, reason string) {
+	registration.writeErrors.With(map[string]string{DomainLabel: r.Host, ReasonLabel: reason}).Inc()
+}
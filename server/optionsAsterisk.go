@@ -0,0 +1,21 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// OptionsAsteriskHandler answers the server-wide OPTIONS * request with HTTP 204 and the allowed methods in the Allow HTTP-Header.
+// The filesystem is not touched for these requests. Requires http.Server.DisableGeneralOptionsHandler,
+// otherwise the http.Server answers OPTIONS * itself with an empty HTTP 200.
+func OptionsAsteriskHandler(next http.Handler, allowedMethods ...string) http.Handler {
+	allow := strings.Join(allowedMethods, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodOptions || r.RequestURI != "*" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Allow", allow)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,40 @@
+package server_test
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionsAsterisk(t *testing.T) {
+	_, _, next := getDefaultHandlerMocks()
+	srv := httptest.NewUnstartedServer(server.OptionsAsteriskHandler(server.ValidateHandler(next), http.MethodGet, http.MethodHead))
+	srv.Config.DisableGeneralOptionsHandler = true
+	srv.Start()
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, conn.Close())
+	}()
+	_, err = conn.Write([]byte("OPTIONS * HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Equal(t, "GET, HEAD", resp.Header.Get("Allow"))
+	require.Nil(t, next.r)
+}
+
+func TestOptionsAsteriskOnlyAsterisk(t *testing.T) {
+	w, _, next := getDefaultHandlerMocks()
+	server.OptionsAsteriskHandler(next, http.MethodGet).ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/index.html", nil))
+	require.NotNil(t, next.r)
+}
@@ -0,0 +1,18 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"runtime/pprof"
+)
+
+// ProfileLabelsHandler attaches the pprof labels method and path to the request handling, so that CPU profiles can be
+// attributed to the requested files. Goroutines started by the next handlers inherit the labels.
+// The path has an unbounded cardinality, hence only meant for debugging.
+func ProfileLabelsHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pprof.Do(r.Context(), pprof.Labels("method", r.Method, "path", r.URL.Path), func(ctx context.Context) {
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+}
@@ -0,0 +1,25 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime/pprof"
+	"testing"
+
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileLabels(t *testing.T) {
+	var method, path string
+	var okMethod, okPath bool
+	handler := server.ProfileLabelsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, okMethod = pprof.Label(r.Context(), "method")
+		path, okPath = pprof.Label(r.Context(), "path")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodHead, "/main.js", nil))
+	require.True(t, okMethod)
+	require.Equal(t, http.MethodHead, method)
+	require.True(t, okPath)
+	require.Equal(t, "/main.js", path)
+}
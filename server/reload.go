@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// ReloadableHandler delegates to a handler that can be swapped atomically at runtime, e.g. after a configuration reload.
+// Requests that are already in progress finish with the previous handler.
+type ReloadableHandler struct {
+	handler atomic.Pointer[http.Handler]
+}
+
+// NewReloadableHandler returns a ReloadableHandler that initially delegates to the given handler.
+func NewReloadableHandler(handler http.Handler) *ReloadableHandler {
+	reloadable := &ReloadableHandler{}
+	reloadable.Swap(handler)
+	return reloadable
+}
+
+// Swap replaces the handler for all subsequent requests.
+func (reloadable *ReloadableHandler) Swap(handler http.Handler) {
+	reloadable.handler.Store(&handler)
+}
+
+func (reloadable *ReloadableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*reloadable.handler.Load()).ServeHTTP(w, r)
+}
+
+// OnSigHup calls reload for every received syscall.SIGHUP until the context is done. Blocks until then.
+// Afterward syscall.SIGHUP is ignored, as its default action would terminate the process during the graceful shutdown.
+func OnSigHup(ctx context.Context, reload func()) {
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	for {
+		select {
+		case <-ctx.Done():
+			signal.Ignore(syscall.SIGHUP)
+			return
+		case <-hupChan:
+			log.Info().Msg("Received SIGHUP, reloading")
+			reload()
+		}
+	}
+}
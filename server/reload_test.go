@@ -0,0 +1,74 @@
+package server_test
+
+import (
+	"context"
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReloadableHandler(t *testing.T) {
+	reloadable := server.NewReloadableHandler(getStaticHandler("old"))
+	require.Equal(t, "old", getReloadableResponse(reloadable))
+	reloadable.Swap(getStaticHandler("new"))
+	require.Equal(t, "new", getReloadableResponse(reloadable))
+}
+
+func TestOnSigHup(t *testing.T) {
+	// prevents the default action (termination) of SIGHUP in case it is received before OnSigHup has registered
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	defer signal.Stop(hupChan)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	reloadable := server.NewReloadableHandler(getStaticHandler("old"))
+	var reloads atomic.Int32
+	go func() {
+		server.OnSigHup(ctx, func() {
+			reloads.Add(1)
+			reloadable.Swap(getStaticHandler("new"))
+		})
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+		return reloads.Load() > 0
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, "new", getReloadableResponse(reloadable))
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		require.Fail(t, "OnSigHup did not return after the context has been cancelled")
+	}
+
+	// without the guard above the signal terminates the test process if not ignored
+	signal.Stop(hupChan)
+	reloadsBefore := reloads.Load()
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+	require.Never(t, func() bool { return reloads.Load() != reloadsBefore }, 50*time.Millisecond, 10*time.Millisecond)
+}
+
+// getStaticHandler returns a handler that always responds with the given body
+func getStaticHandler(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	})
+}
+
+// getReloadableResponse returns the response body of the handler for a dummy request
+func getReloadableResponse(handler http.Handler) string {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return w.Body.String()
+}
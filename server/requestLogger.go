@@ -0,0 +1,33 @@
+package server
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"net/http"
+)
+
+// RequestLoggerHandler stores a child logger of the global logger in the request context that carries the request id
+// from the chi RequestID middleware. The handlers of this package log via this logger, it is also available via log.Ctx.
+// Has to be placed after the chi RequestID middleware.
+func RequestLoggerHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := middleware.GetReqID(r.Context())
+		if requestId == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		logger := log.With().Str("requestId", requestId).Logger()
+		next.ServeHTTP(w, r.WithContext(logger.WithContext(r.Context())))
+	})
+}
+
+// requestLogger returns the logger from the context or the global logger if none is present.
+func requestLogger(ctx context.Context) *zerolog.Logger {
+	logger := zerolog.Ctx(ctx)
+	if logger.GetLevel() == zerolog.Disabled {
+		return &log.Logger
+	}
+	return logger
+}
@@ -0,0 +1,48 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestLogger(t *testing.T) {
+	var logOutput bytes.Buffer
+	originalLogger := log.Logger
+	log.Logger = zerolog.New(&logOutput)
+	defer func() {
+		log.Logger = originalLogger
+	}()
+
+	var requestId string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId = middleware.GetReqID(r.Context())
+	})
+	// the rejection of the host allowlist is logged with the request logger
+	handler := middleware.RequestID(server.RequestLoggerHandler(server.HostAllowlistHandler(next, []string{"example.com"})))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Host = "other.com"
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	require.Empty(t, requestId)
+
+	var logEntry map[string]any
+	require.NoError(t, json.Unmarshal(logOutput.Bytes(), &logEntry))
+	require.NotEmpty(t, logEntry["requestId"])
+}
+
+func TestRequestLoggerContext(t *testing.T) {
+	var logger *zerolog.Logger
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger = log.Ctx(r.Context())
+	})
+	handler := middleware.RequestID(server.RequestLoggerHandler(next))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NotEqual(t, zerolog.Disabled, logger.GetLevel())
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"context"
+	"net/http"
+)
+
+// servedPathKey is the ContextKey under which the filesystem path of the served file is reported to the access log
+var servedPathKey = &ContextKey{val: "servedPath"}
+
+// servedPath holds the filesystem path of the served file after all rewrites, e.g. by the fallback or index handlers.
+type servedPath struct {
+	reported bool
+	path     string
+}
+
+// withServedPath adds a servedPath to the request context where the path of the served file will be reported.
+func withServedPath(r *http.Request) (*http.Request, *servedPath) {
+	served := &servedPath{}
+	return r.WithContext(context.WithValue(r.Context(), servedPathKey, served)), served
+}
+
+// ReportServedPath reports the filesystem path of the served file to the access log.
+// Repeated reports for the same request, e.g. due to the FallbackHandler, overwrite the previous path.
+// It is a no-op if the access log does not record the served path.
+func ReportServedPath(ctx context.Context, path string) {
+	if served, ok := ctx.Value(servedPathKey).(*servedPath); ok {
+		served.reported = true
+		served.path = path
+	}
+}
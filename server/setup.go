@@ -1,10 +1,13 @@
 package server
 
 import (
+	"github.com/ngergs/websrv/v3/filesystem"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
+	"io/fs"
 	"net"
 	"net/http"
+	"regexp"
 	"strconv"
 	"time"
 )
@@ -69,6 +72,35 @@ func Caching() HandlerMiddleware {
 	}
 }
 
+// CachingWithHash is like Caching, but uses the given hash function for the ETag and stores at most maxEntries hashes.
+func CachingWithHash(hash HashFunc, maxEntries int) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return NewCacheHandlerWithHash(handler, hash, maxEntries)
+	}
+}
+
+// CachingWithOptions is like Caching, but uses the given CacheOptions, e.g. to add the Content-Digest HTTP-Header.
+func CachingWithOptions(opts CacheOptions) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return NewCacheHandlerWithOptions(handler, opts)
+	}
+}
+
+// CacheBustingQuery adds a middleware that marks successful responses as immutable if the queryParam is present in the request.
+func CacheBustingQuery(queryParam string) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return CacheBustingQueryHandler(handler, queryParam)
+	}
+}
+
+// HashedPath adds a middleware that removes the hash segment at the zero-based segment index from the request path
+// if it matches the pattern and marks successful responses as immutable.
+func HashedPath(segment int, pattern *regexp.Regexp) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return HashedPathHandler(handler, segment, pattern)
+	}
+}
+
 // CspHeaderReplace replaces the nonce variable in the Content-Security-Header.
 func CspHeaderReplace(variableName string) HandlerMiddleware {
 	return func(handler http.Handler) http.Handler {
@@ -84,6 +116,29 @@ func CspFileReplace(variableName string, mediaTypeMap map[string]string) Handler
 	}
 }
 
+// CspFileReplaceWithMetrics is like CspFileReplace but counts the failed template renderings in the metrics registration.
+func CspFileReplaceWithMetrics(variableName string, mediaTypeMap map[string]string, registration *PrometheusRegistration) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		cspHandler := NewCspFileHandler(handler, variableName, mediaTypeMap)
+		cspHandler.Metrics = registration
+		return cspHandler
+	}
+}
+
+// EarlyHints adds a middleware that sends the Link HTTP-Headers of the matching rules as HTTP 103 Early Hints to HTTP/2 and later clients.
+func EarlyHints(rules []LinkRule) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return EarlyHintsHandler(handler, rules)
+	}
+}
+
+// LinkHeader adds a middleware that adds the Link HTTP-Headers of the matching rules to HTML responses.
+func LinkHeader(rules []LinkRule) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return LinkHeaderHandler(handler, rules)
+	}
+}
+
 // SessionId adds a session cookie adding middleware
 func SessionId(cookieName string, cookieMaxAge time.Duration) HandlerMiddleware {
 	return func(handler http.Handler) http.Handler {
@@ -109,6 +164,220 @@ func Fallback(fallbackPath string, fallbackCodes ...int) HandlerMiddleware {
 	}
 }
 
+// FallbackWithCacheControl adds a fallback route handler whose responses use the given Cache-Control HTTP-Header.
+func FallbackWithCacheControl(fallbackPath string, cacheControl string, fallbackCodes ...int) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return FallbackHandlerWithCacheControl(handler, fallbackPath, cacheControl, fallbackCodes...)
+	}
+}
+
+// FallbackWithOptions adds a fallback route handler with the given FallbackOptions.
+func FallbackWithOptions(fallbackPath string, opts FallbackOptions, fallbackCodes ...int) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return FallbackHandlerWithOptions(handler, fallbackPath, opts, fallbackCodes...)
+	}
+}
+
+// Authorization adds a middleware that asks the authorizer whether a request is allowed to be served.
+// Denied requests are answered with the denyBody.
+func Authorization(authorizer Authorizer, denyBody string) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return AuthorizationHandler(handler, authorizer, denyBody)
+	}
+}
+
+// BaseHref adds a middleware that rewrites the <base href> of HTML responses to the given basePath.
+func BaseHref(basePath string) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return BaseHrefHandler(handler, basePath)
+	}
+}
+
+// BomStrip adds a middleware that strips a leading UTF-8 byte order mark from responses of the given media types.
+func BomStrip(mediaTypes []string) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return BomStripHandler(handler, mediaTypes)
+	}
+}
+
+// Favicon adds a middleware that serves the fallbackPath or HTTP 204 (if empty) when no favicon is present.
+func Favicon(fallbackPath string) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return FaviconHandler(handler, fallbackPath)
+	}
+}
+
+// Throttle adds a middleware that delays each response by the latency and throttles the response body to bytesPerSecond.
+// Only meant for testing loading states during development.
+func Throttle(latency time.Duration, bytesPerSecond int) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return ThrottleHandler(handler, latency, bytesPerSecond)
+	}
+}
+
+// CompressionStats adds a middleware that reports the uncompressed response size to the access log.
+// It has to be placed directly inside the compression middleware.
+func CompressionStats() HandlerMiddleware {
+	return CompressionStatsHandler
+}
+
+// HostAllowlist adds a middleware that rejects requests whose Host header is not in the allowedHosts.
+func HostAllowlist(allowedHosts []string) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return HostAllowlistHandler(handler, allowedHosts)
+	}
+}
+
+// MaxPathLength adds a middleware that rejects requests with paths longer than maxLength bytes with HTTP 414.
+func MaxPathLength(maxLength int) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return MaxPathLengthHandler(handler, maxLength)
+	}
+}
+
+// FixedResponses adds a middleware that serves inline responses for exact request paths, see FixedResponseHandler.
+func FixedResponses(fsys fs.FS, responses map[string]FixedResponse) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return FixedResponseHandler(handler, fsys, responses)
+	}
+}
+
+// Hsts adds a middleware that sets the Strict-Transport-Security HTTP-Header for requests over TLS or trusted forwarded HTTPS requests.
+func Hsts(opts HstsOptions) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return HstsHandler(handler, opts)
+	}
+}
+
+// ProfileLabels adds a middleware that attaches the request method and path as pprof labels, see ProfileLabelsHandler.
+func ProfileLabels() HandlerMiddleware {
+	return ProfileLabelsHandler
+}
+
+// OptionsAsterisk adds a middleware that answers OPTIONS * with HTTP 204 and the allowed methods in the Allow HTTP-Header.
+func OptionsAsterisk(allowedMethods ...string) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return OptionsAsteriskHandler(handler, allowedMethods...)
+	}
+}
+
+// NotFoundSuggestion adds a middleware that suggests similar file names from the filesystem in HTTP 404 responses.
+// Only meant for development as the suggestions reveal the filesystem structure.
+func NotFoundSuggestion(fsys fs.FS) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return NotFoundSuggestionHandler(handler, fsys)
+	}
+}
+
+// NotFoundSuggestionWithOptions adds a middleware like NotFoundSuggestion that does not suggest files
+// which would be rejected by the HiddenHandler or AllowedExtensionsHandler with the given options.
+func NotFoundSuggestionWithOptions(fsys fs.FS, opts NotFoundSuggestionOptions) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return NotFoundSuggestionHandlerWithOptions(handler, fsys, opts)
+	}
+}
+
+// NegotiatedError adds a middleware that replaces the response bodies for the given status codes with Accept-dependent error bodies.
+func NegotiatedError(codes ...int) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return NegotiatedErrorHandler(handler, codes...)
+	}
+}
+
+// HitCounting adds a middleware that counts the successful responses per request path.
+func HitCounting(counter *HitCounter) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return HitCounterHandler(handler, counter)
+	}
+}
+
+// CacheControlRules adds a middleware that sets the Cache-Control HTTP-Header according to the first rule that matches the request path.
+func CacheControlRules(rules []CacheControlRule) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return CacheControlRulesHandler(handler, rules)
+	}
+}
+
+// RequestLogger adds a middleware that stores a logger with the request id in the request context.
+// Has to be placed after the chi RequestID middleware.
+func RequestLogger() HandlerMiddleware {
+	return RequestLoggerHandler
+}
+
+// Index adds a middleware that serves the first existing of the indexFiles for directory requests.
+func Index(fsys fs.FS, indexFiles []string) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return IndexHandler(handler, fsys, indexFiles)
+	}
+}
+
+// EmptyFile adds a middleware that responds with HTTP 204 to GET requests for zero-byte files.
+func EmptyFile(fsys fs.FS) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return EmptyFileHandler(handler, fsys)
+	}
+}
+
+// CanonicalQuery adds a middleware that canonicalizes the query string of GET and HEAD requests.
+func CanonicalQuery(options CanonicalQueryOptions) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return CanonicalQueryHandler(handler, options)
+	}
+}
+
+// Charset adds a middleware that detects the charset of text responses and declares it or transcodes to UTF-8, see CharsetHandler.
+func Charset(opts CharsetOptions) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return CharsetHandler(handler, opts)
+	}
+}
+
+// WriteErrors adds a middleware that logs errors while writing the response body and records them in the registration if not nil.
+func WriteErrors(registration *PrometheusRegistration) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		if registration == nil {
+			return WriteErrorHandler(handler, nil)
+		}
+		return WriteErrorHandler(handler, registration)
+	}
+}
+
+// Hidden adds a middleware that responds with HTTP 404 to requests for hidden paths like dotfiles, see HiddenHandler.
+func Hidden(opts HiddenOptions) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return HiddenHandler(handler, opts)
+	}
+}
+
+// AllowedExtensions adds a middleware that rejects requests for file extensions that are not allowed
+// and for files without extension with HTTP 404.
+func AllowedExtensions(fsys fs.FS, extensions []string) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return AllowedExtensionsHandler(handler, fsys, extensions)
+	}
+}
+
+// CircuitBreaker adds a middleware that responds with HTTP 503 while the circuit breaker of the filesystem is open.
+func CircuitBreaker(breaker *filesystem.CircuitBreaker) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return CircuitBreakerHandler(handler, breaker)
+	}
+}
+
+// Attachment adds a middleware that serves the responses that match the options as download.
+func Attachment(options AttachmentOptions) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return AttachmentHandler(handler, options)
+	}
+}
+
+// LegalBlock adds a middleware that responds with HTTP 451 for request paths that match one of the rules.
+func LegalBlock(rules []LegalBlockRule) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return LegalBlockHandler(handler, rules)
+	}
+}
+
 // Validate adds to the validate middleware and prevent path transversal attacks by cleaning the request path.
 func Validate() HandlerMiddleware {
 	return ValidateHandler
@@ -119,6 +388,13 @@ func AccessLog() HandlerMiddleware {
 	return AccessLogHandler
 }
 
+// AccessLogWithOptions adds an access logging middleware with the given options.
+func AccessLogWithOptions(options AccessLogOptions) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return AccessLogHandlerWithOptions(handler, options)
+	}
+}
+
 // AccessMetrics collects metrics about bytes send and response status codes and writes
 // them to the provided prometheus registerer.
 func AccessMetrics(registration *PrometheusRegistration) HandlerMiddleware {
@@ -127,6 +403,20 @@ func AccessMetrics(registration *PrometheusRegistration) HandlerMiddleware {
 	}
 }
 
+// AccessMetricsRecorder adds a middleware that passes the access metrics to the given recorder.
+func AccessMetricsRecorder(recorder MetricsRecorder) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return AccessMetricsRecorderHandler(handler, recorder)
+	}
+}
+
+// InFlight adds a middleware that keeps track of the in-flight requests via the given counter.
+func InFlight(counter *InFlightCounter) HandlerMiddleware {
+	return func(handler http.Handler) http.Handler {
+		return InFlightHandler(handler, counter)
+	}
+}
+
 // H2C adds a middleware that supports h2c (unencrypted http2)
 func H2C(h2cPort uint16) HandlerMiddleware {
 	h2s := &http2.Server{}
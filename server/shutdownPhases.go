@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ShutdownPhase determines the order in which the Shutdowner are called by the ShutdownPhases.
+type ShutdownPhase int
+
+const (
+	// ShutdownPhaseServe drains the servers that serve the actual content
+	ShutdownPhaseServe ShutdownPhase = iota
+	// ShutdownPhaseMetrics shuts down the metrics and health servers and flushes metrics
+	ShutdownPhaseMetrics
+	// ShutdownPhaseLogs flushes and closes the log sinks, so that the logs of all previous phases are captured
+	ShutdownPhaseLogs
+	shutdownPhaseCount
+)
+
+// ShutdownFunc adapts a function to the Shutdowner interface.
+type ShutdownFunc func(ctx context.Context) error
+
+// Shutdown calls the function.
+func (f ShutdownFunc) Shutdown(ctx context.Context) error {
+	return f(ctx)
+}
+
+type shutdownRegistration struct {
+	name       string
+	shutdowner Shutdowner
+	timeout    time.Duration
+}
+
+// ShutdownPhases calls the registered Shutdowner ordered by their ShutdownPhase.
+// Shutdowner of the same phase are called concurrently, the next phase starts once all of them have returned.
+type ShutdownPhases struct {
+	mutex  sync.Mutex
+	phases [shutdownPhaseCount][]shutdownRegistration
+}
+
+// Register adds the shutdowner to the given phase. The name is used for logging and timeout is the deadline for its Shutdown call.
+func (phases *ShutdownPhases) Register(phase ShutdownPhase, name string, shutdowner Shutdowner, timeout time.Duration) {
+	phases.mutex.Lock()
+	defer phases.mutex.Unlock()
+	phases.phases[phase] = append(phases.phases[phase], shutdownRegistration{name: name, shutdowner: shutdowner, timeout: timeout})
+}
+
+// Run waits till the ctx is done and then calls the Shutdown of the registered Shutdowner phase by phase. Blocks till then.
+// The errors of all Shutdown calls are returned joined together.
+//
+//nolint:contextcheck // we can't reuse the already closed context for the shutdown deadline
+func (phases *ShutdownPhases) Run(ctx context.Context) error {
+	<-ctx.Done()
+	phases.mutex.Lock()
+	defer phases.mutex.Unlock()
+	var errs []error
+	var errMutex sync.Mutex
+	for _, registrations := range phases.phases {
+		var wg sync.WaitGroup
+		for _, registration := range registrations {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				shutdownCtx := context.WithValue(context.Background(), ServerName, registration.name)
+				logShutdown(shutdownCtx, registration.timeout)
+				shutdownCtx, cancel := context.WithTimeout(shutdownCtx, registration.timeout)
+				defer cancel()
+				if err := registration.shutdowner.Shutdown(shutdownCtx); err != nil {
+					errMutex.Lock()
+					errs = append(errs, err)
+					errMutex.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	return errors.Join(errs...)
+}
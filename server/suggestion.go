@@ -0,0 +1,110 @@
+package server
+
+import (
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const (
+	// suggestionMaxEntries bounds the number of directory entries that are compared to the requested file name
+	suggestionMaxEntries = 1000
+	// suggestionMaxDistance is the maximal edit distance for a file name to be suggested
+	suggestionMaxDistance = 2
+)
+
+// NotFoundSuggestionOptions determines which files are never suggested by the NotFoundSuggestionHandlerWithOptions.
+type NotFoundSuggestionOptions struct {
+	// Hidden are the options of the HiddenHandler, hidden files are not suggested
+	Hidden HiddenOptions
+	// AllowedExtensions are the extensions of the AllowedExtensionsHandler, other files are not suggested. Empty allows all.
+	AllowedExtensions []string
+}
+
+// NotFoundSuggestionHandler adds a "did you mean" suggestion to HTTP 404 responses of the next handler.
+// Only the directory of the requested file is searched for a file name that differs in case or by a small edit distance.
+// Suggestions reveal the filesystem structure and are therefore only meant for development.
+func NotFoundSuggestionHandler(next http.Handler, fsys fs.FS) http.Handler {
+	return NotFoundSuggestionHandlerWithOptions(next, fsys, NotFoundSuggestionOptions{})
+}
+
+// NotFoundSuggestionHandlerWithOptions is the NotFoundSuggestionHandler that does not suggest files
+// which would be rejected by the HiddenHandler or AllowedExtensionsHandler with the given options.
+func NotFoundSuggestionHandlerWithOptions(next http.Handler, fsys fs.FS, opts NotFoundSuggestionOptions) http.Handler {
+	var allowed map[string]bool
+	if len(opts.AllowedExtensions) != 0 {
+		allowed = extensionSet(opts.AllowedExtensions)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// handlers further down the chain like the FallbackHandler may modify the request path
+		requestPath := r.URL.Path
+		interceptStatus(next, []int{http.StatusNotFound}, func(w http.ResponseWriter, r *http.Request, status int) {
+			suggestion := suggestFile(fsys, requestPath, func(candidate fs.DirEntry, candidatePath string) bool {
+				return opts.Hidden.hides(candidatePath) ||
+					(allowed != nil && rejectsExtension(allowed, candidatePath, candidate.Type().IsRegular))
+			})
+			if suggestion == "" {
+				Error(w, r, "", status)
+				return
+			}
+			requestLogger(r.Context()).Info().Msgf("File %s not found, did you mean %s?", requestPath, suggestion)
+			Error(w, r, "not found, did you mean "+suggestion+"?", status)
+		}).ServeHTTP(w, r)
+	})
+}
+
+// suggestFile returns the path of the closest matching file in the directory of the requestPath or an empty string if none is close enough.
+// Entries for which skip returns true are not suggested.
+func suggestFile(fsys fs.FS, requestPath string, skip func(entry fs.DirEntry, entryPath string) bool) string {
+	dir, name := path.Split(path.Clean("/" + requestPath))
+	if name == "" {
+		return ""
+	}
+	dirName := strings.Trim(dir, "/")
+	if dirName == "" {
+		dirName = "."
+	}
+	entries, err := fs.ReadDir(fsys, dirName)
+	if err != nil {
+		return ""
+	}
+	if len(entries) > suggestionMaxEntries {
+		entries = entries[:suggestionMaxEntries]
+	}
+	suggestion := ""
+	bestDistance := suggestionMaxDistance + 1
+	lowerName := strings.ToLower(name)
+	for _, entry := range entries {
+		if entry.Name() == name || skip(entry, dir+entry.Name()) {
+			continue
+		}
+		distance := levenshteinDistance(lowerName, strings.ToLower(entry.Name()))
+		if distance < bestDistance {
+			bestDistance = distance
+			suggestion = dir + entry.Name()
+		}
+	}
+	return suggestion
+}
+
+// levenshteinDistance returns the minimal number of single byte insertions, deletions and substitutions to convert a into b.
+func levenshteinDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,73 @@
+package server_test
+
+import (
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNotFoundSuggestion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"assets/Logo.png": &fstest.MapFile{Data: []byte(dummyResponse)},
+		"assets/main.js":  &fstest.MapFile{Data: []byte(dummyResponse)},
+		"index.html":      &fstest.MapFile{Data: []byte(dummyResponse)},
+	}
+	handler := server.NotFoundSuggestionHandler(http.FileServer(http.FS(fsys)), fsys)
+	for requestPath, suggestion := range map[string]string{
+		"/assets/logo.png": "/assets/Logo.png",
+		"/assets/mian.js":  "/assets/main.js",
+		"/index.htm":       "/index.html",
+		"/unrelated.css":   "",
+		"/missing/main.js": "",
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, requestPath, nil))
+		require.Equal(t, http.StatusNotFound, w.Code)
+		if suggestion == "" {
+			require.NotContains(t, w.Body.String(), "did you mean", requestPath)
+		} else {
+			require.Contains(t, w.Body.String(), "did you mean "+suggestion+"?", requestPath)
+		}
+	}
+}
+
+func TestNotFoundSuggestionFound(t *testing.T) {
+	fsys := fstest.MapFS{"main.js": &fstest.MapFile{Data: []byte(dummyResponse)}}
+	w := httptest.NewRecorder()
+	server.NotFoundSuggestionHandler(http.FileServer(http.FS(fsys)), fsys).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/main.js", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, dummyResponse, w.Body.String())
+}
+
+func TestNotFoundSuggestionSkipsRejected(t *testing.T) {
+	fsys := fstest.MapFS{
+		".env":        &fstest.MapFile{Data: []byte(dummyResponse)},
+		"main.js.map": &fstest.MapFile{Data: []byte(dummyResponse)},
+		"main.js.bak": &fstest.MapFile{Data: []byte(dummyResponse)},
+		"Makefile":    &fstest.MapFile{Data: []byte(dummyResponse)},
+		"secret.txt":  &fstest.MapFile{Data: []byte(dummyResponse)},
+		"index.html":  &fstest.MapFile{Data: []byte(dummyResponse)},
+	}
+	handler := server.NotFoundSuggestionHandlerWithOptions(http.FileServer(http.FS(fsys)), fsys, server.NotFoundSuggestionOptions{
+		Hidden:            server.HiddenOptions{Dotfiles: true, Patterns: []*regexp.Regexp{regexp.MustCompile(`^/secret\.`)}},
+		AllowedExtensions: []string{".html", ".txt"},
+	})
+	for _, requestPath := range []string{"/envv", "/main.js.ma", "/main.js.ba", "/makefile", "/secret.tx"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, requestPath, nil))
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.NotContains(t, w.Body.String(), "did you mean", requestPath)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/index.htm", nil))
+	require.Contains(t, w.Body.String(), "did you mean /index.html?")
+
+	// without options the rejected files are suggested
+	w = httptest.NewRecorder()
+	server.NotFoundSuggestionHandler(http.FileServer(http.FS(fsys)), fsys).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/envv", nil))
+	require.Contains(t, w.Body.String(), "did you mean /.env?")
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"github.com/felixge/httpsnoop"
+	"io"
+	"net/http"
+	"time"
+)
+
+// how many chunks per second are written by the throttled writer
+const throttleChunksPerSecond = 10
+
+// ThrottleHandler delays each response by the given latency and throttles the response body to the given bytes per second.
+// A bytesPerSecond value of 0 disables the bandwidth throttling. This is only meant for testing loading states during development.
+func ThrottleHandler(next http.Handler, latency time.Duration, bytesPerSecond int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if latency > 0 {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(latency):
+			}
+		}
+		if bytesPerSecond <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		throttled := &throttledWriter{ctx: r.Context(), bytesPerSecond: bytesPerSecond, start: time.Now()}
+		wrappedW := httpsnoop.Wrap(w, httpsnoop.Hooks{
+			Write: func(writeFunc httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+				throttled.write = writeFunc
+				return throttled.Write
+			},
+			ReadFrom: func(_ httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+				return func(src io.Reader) (int64, error) {
+					return io.Copy(throttled, src)
+				}
+			},
+		})
+		next.ServeHTTP(wrappedW, r)
+	})
+}
+
+// throttledWriter paces the writes to the underlying write function to the given bytes per second.
+//
+//nolint:containedctx // the writer only lives for the duration of the request
+type throttledWriter struct {
+	ctx            context.Context
+	write          httpsnoop.WriteFunc
+	bytesPerSecond int
+	start          time.Time
+	written        int64
+}
+
+func (tw *throttledWriter) Write(b []byte) (int, error) {
+	chunkSize := max(tw.bytesPerSecond/throttleChunksPerSecond, 1)
+	n := 0
+	for n < len(b) {
+		m, err := tw.write(b[n:min(n+chunkSize, len(b))])
+		n += m
+		tw.written += int64(m)
+		if err != nil {
+			return n, err
+		}
+		wait := time.Duration(float64(tw.written)/float64(tw.bytesPerSecond)*float64(time.Second)) - time.Since(tw.start)
+		if wait > 0 {
+			select {
+			case <-tw.ctx.Done():
+				return n, tw.ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,34 @@
+package server_test
+
+import (
+	"bytes"
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestThrottleLatency(t *testing.T) {
+	latency := time.Duration(100) * time.Millisecond
+	w, r, next := getDefaultHandlerMocks()
+	handler := server.ThrottleHandler(next, latency, 0)
+	start := time.Now()
+	handler.ServeHTTP(w, r)
+	require.GreaterOrEqual(t, time.Since(start), latency)
+}
+
+func TestThrottleBandwidth(t *testing.T) {
+	data := bytes.Repeat([]byte("a"), 1000)
+	w, r, next := getDefaultHandlerMocks()
+	next.serveHttpFunc = func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write(data)
+		require.NoError(t, err)
+	}
+	// 1000 bytes at 5000 bytes per second should take at least 200ms
+	handler := server.ThrottleHandler(next, 0, 5000)
+	start := time.Now()
+	handler.ServeHTTP(w, r)
+	require.GreaterOrEqual(t, time.Since(start), time.Duration(200)*time.Millisecond)
+	require.Equal(t, data, w.Body.Bytes())
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"bytes"
+	"github.com/felixge/httpsnoop"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// transformFilter decides based on the response status and headers whether the response body should be transformed.
+type transformFilter func(status int, header http.Header) bool
+
+// transformFunc transforms the complete response body. The response headers may be adjusted.
+type transformFunc func(header http.Header, body []byte) []byte
+
+// transformHandler buffers the response body of the next handler when the filter matches and writes it out after applying the transform.
+// Only uncompressed HTTP 200 responses are considered. The Content-Length HTTP-Header is adjusted to the transformed body.
+func transformHandler(next http.Handler, filter transformFilter, transform transformFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf *bytes.Buffer
+		status := http.StatusOK
+		decided := false
+		decide := func(code int) {
+			decided = true
+			status = code
+			if code != http.StatusOK || w.Header().Get("Content-Encoding") != "" || !filter(code, w.Header()) {
+				return
+			}
+			if r.Method == http.MethodHead {
+				// no body to transform, but the Content-Length of the untransformed body is not valid anymore
+				w.Header().Del("Content-Length")
+				return
+			}
+			buf = &bytes.Buffer{}
+		}
+		wrappedW := httpsnoop.Wrap(w, httpsnoop.Hooks{
+			WriteHeader: func(headerFunc httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+				return func(code int) {
+					// informational responses are not final
+					if code < http.StatusOK || decided {
+						headerFunc(code)
+						return
+					}
+					decide(code)
+					if buf == nil {
+						headerFunc(code)
+					}
+				}
+			},
+			Write: func(writeFunc httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+				return func(b []byte) (int, error) {
+					if !decided {
+						decide(http.StatusOK)
+					}
+					if buf != nil {
+						return buf.Write(b)
+					}
+					return writeFunc(b)
+				}
+			},
+			ReadFrom: func(fromFunc httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+				return func(src io.Reader) (int64, error) {
+					if !decided {
+						decide(http.StatusOK)
+					}
+					if buf != nil {
+						return io.Copy(buf, src)
+					}
+					return fromFunc(src)
+				}
+			},
+		})
+		next.ServeHTTP(wrappedW, r)
+		if buf == nil {
+			return
+		}
+
+		data := transform(w.Header(), buf.Bytes())
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(status)
+		// write errors are reported by the WriteErrorHandler
+		_, _ = w.Write(data)
+	})
+}
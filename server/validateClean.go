@@ -10,11 +10,11 @@ import (
 func ValidateHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet && r.Method != http.MethodHead {
-			http.Error(w, "This server only supports HTTP methods GET and HEAD", http.StatusMethodNotAllowed)
+			Error(w, r, "This server only supports HTTP methods GET and HEAD", http.StatusMethodNotAllowed)
 			return
 		}
 		if !path.IsAbs(r.URL.Path) {
-			http.Error(w, "", http.StatusBadRequest)
+			Error(w, r, "", http.StatusBadRequest)
 			return
 		}
 
@@ -1,10 +1,14 @@
 package server_test
 
 import (
+	"bufio"
 	"github.com/ngergs/websrv/v3/server"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -53,3 +57,30 @@ func TestCleanPathTransversalAttack(t *testing.T) {
 	}()
 	require.Equal(t, "/a/c", r.URL.Path)
 }
+
+// TestValidateExpectContinue checks that a rejected request with Expect: 100-continue is answered
+// promptly without waiting for the request body.
+func TestValidateExpectContinue(t *testing.T) {
+	_, _, next := getDefaultHandlerMocks()
+	testServer := httptest.NewServer(server.ValidateHandler(next))
+	defer testServer.Close()
+
+	conn, err := net.Dial("tcp", testServer.Listener.Addr().String())
+	require.NoError(t, err)
+	defer func() {
+		err := conn.Close()
+		require.NoError(t, err)
+	}()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(time.Duration(2)*time.Second)))
+	_, err = conn.Write([]byte("POST /index.html HTTP/1.1\r\nHost: localhost\r\nExpect: 100-continue\r\nContent-Length: 1024\r\n\r\n"))
+	require.NoError(t, err)
+
+	response, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	defer func() {
+		err := response.Body.Close()
+		require.NoError(t, err)
+	}()
+	require.Equal(t, http.StatusMethodNotAllowed, response.StatusCode)
+	require.True(t, response.Close)
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"syscall"
+
+	"github.com/felixge/httpsnoop"
+)
+
+const (
+	// WriteErrorClientDisconnect is the reason for write errors due to a client that has left
+	WriteErrorClientDisconnect = "client_disconnect"
+	// WriteErrorOther is the reason for all other write errors
+	WriteErrorOther = "error"
+)
+
+// WriteErrorHandler detects errors while writing the response body, which are not visible in the HTTP status code.
+// Client disconnects are logged on the debug level, other write errors on the warn level, and both are passed to the recorder if not nil.
+func WriteErrorHandler(next http.Handler, recorder WriteErrorRecorder) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var writeErr error
+		wrappedW := httpsnoop.Wrap(w, httpsnoop.Hooks{
+			Write: func(writeFunc httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+				return func(b []byte) (int, error) {
+					n, err := writeFunc(b)
+					if err != nil && writeErr == nil {
+						writeErr = err
+					}
+					return n, err
+				}
+			},
+			ReadFrom: func(fromFunc httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+				return func(src io.Reader) (int64, error) {
+					n, err := fromFunc(src)
+					if err != nil && writeErr == nil {
+						writeErr = err
+					}
+					return n, err
+				}
+			},
+		})
+		next.ServeHTTP(wrappedW, r)
+		if writeErr == nil {
+			return
+		}
+		reason := writeErrorReason(r, writeErr)
+		if recorder != nil {
+			recorder.RecordWriteError(r, reason)
+		}
+		if reason == WriteErrorClientDisconnect {
+			requestLogger(r.Context()).Debug().Err(writeErr).Msgf("client disconnected while writing the response for %s", r.URL.Path)
+			return
+		}
+		requestLogger(r.Context()).Warn().Err(writeErr).Msgf("error writing the response for %s", r.URL.Path)
+	})
+}
+
+// writeErrorReason returns WriteErrorClientDisconnect if the request context has been cancelled or the connection
+// has been closed by the client and WriteErrorOther otherwise.
+func writeErrorReason(r *http.Request, err error) string {
+	if errors.Is(r.Context().Err(), context.Canceled) || errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
+		return WriteErrorClientDisconnect
+	}
+	return WriteErrorOther
+}
@@ -0,0 +1,77 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+
+	"github.com/ngergs/websrv/v3/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// failingWriter is a http.ResponseWriter whose body writes fail with err
+type failingWriter struct {
+	*httptest.ResponseRecorder
+	err error
+}
+
+func (w *failingWriter) Write(_ []byte) (int, error) {
+	return 0, w.err
+}
+
+func TestWriteErrors(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registration, err := server.AccessMetricsRegister(registry, metricsNamespace)
+	require.NoError(t, err)
+	handler := server.WriteErrorHandler(getStaticHandler(dummyResponse), registration)
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		err    error
+		reason string
+		level  string
+	}{
+		{name: "broken pipe", ctx: context.Background(), err: syscall.EPIPE, reason: server.WriteErrorClientDisconnect, level: "debug"},
+		{name: "connection reset", ctx: context.Background(), err: syscall.ECONNRESET, reason: server.WriteErrorClientDisconnect, level: "debug"},
+		{name: "cancelled request", ctx: cancelledCtx, err: errors.New("write failed"), reason: server.WriteErrorClientDisconnect, level: "debug"},
+		{name: "other error", ctx: context.Background(), err: errors.New("write failed"), reason: server.WriteErrorOther, level: "warn"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var logOutput bytes.Buffer
+			logger := zerolog.New(&logOutput).With().Str("requestId", "abc").Logger()
+			r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(logger.WithContext(test.ctx))
+			before := getCounterValue(t, registry, metricsNamespace+"_access_write_errors", map[string]string{server.ReasonLabel: test.reason})
+			handler.ServeHTTP(&failingWriter{ResponseRecorder: httptest.NewRecorder(), err: test.err}, r)
+			require.InDelta(t, before+1, getCounterValue(t, registry, metricsNamespace+"_access_write_errors", map[string]string{server.ReasonLabel: test.reason}), 0)
+
+			var logEntry map[string]any
+			require.NoError(t, json.Unmarshal(logOutput.Bytes(), &logEntry))
+			require.Equal(t, test.level, logEntry["level"])
+			require.Equal(t, "abc", logEntry["requestId"])
+		})
+	}
+}
+
+func TestWriteErrorsNone(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registration, err := server.AccessMetricsRegister(registry, metricsNamespace)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	server.WriteErrorHandler(getStaticHandler(dummyResponse), registration).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, dummyResponse, w.Body.String())
+	require.InDelta(t, 0, getCounterValue(t, registry, metricsNamespace+"_access_write_errors", map[string]string{}), 0)
+
+	// without recorder the errors are only logged
+	server.WriteErrors(nil)(getStaticHandler(dummyResponse)).ServeHTTP(&failingWriter{ResponseRecorder: httptest.NewRecorder(), err: syscall.EPIPE}, httptest.NewRequest(http.MethodGet, "/", nil))
+}
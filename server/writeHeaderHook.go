@@ -0,0 +1,41 @@
+package server
+
+import (
+	"github.com/felixge/httpsnoop"
+	"io"
+	"net/http"
+)
+
+// wrapWriteHeader wraps the http.ResponseWriter and calls the hook once directly before the final status code is written.
+// Informational status codes are ignored. The response headers may still be modified within the hook.
+func wrapWriteHeader(w http.ResponseWriter, hook func(code int)) http.ResponseWriter {
+	called := false
+	callOnce := func(code int) {
+		if !called {
+			called = true
+			hook(code)
+		}
+	}
+	return httpsnoop.Wrap(w, httpsnoop.Hooks{
+		WriteHeader: func(headerFunc httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+			return func(code int) {
+				if code >= http.StatusOK {
+					callOnce(code)
+				}
+				headerFunc(code)
+			}
+		},
+		Write: func(writeFunc httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+			return func(b []byte) (int, error) {
+				callOnce(http.StatusOK)
+				return writeFunc(b)
+			}
+		},
+		ReadFrom: func(fromFunc httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+			return func(src io.Reader) (int64, error) {
+				callOnce(http.StatusOK)
+				return fromFunc(src)
+			}
+		},
+	})
+}